@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var errOffline = errors.New("network access disabled by -offline")
+
+// AssetConfig controls where remote test assets are fetched from.
+type AssetConfig struct {
+	// Offline refuses all network fetches.
+	Offline bool
+	// Mirror is a base URL that replaces the scheme and host of every remote
+	// asset URL. The original path is kept, so a mirror of
+	// https://github.com/jsando/videos-for-testing/releases/download/v1.0/x.mp4
+	// is expected at <mirror>/jsando/videos-for-testing/releases/download/v1.0/x.mp4.
+	Mirror string
+
+	// ctx, if set, cancels downloads in progress.
+	ctx context.Context
+	// out, if set, receives download progress instead of stdout.
+	out io.Writer
+}
+
+// output returns where download progress is printed.
+func (c *AssetConfig) output() io.Writer {
+	if c.out == nil {
+		return os.Stdout
+	}
+	return c.out
+}
+
+// RemoteTest is implemented by tests that load pages or assets from the
+// network at run time rather than from the local cache.
+type RemoteTest interface {
+	RemoteURLs() []string
+}
+
+// MissingAssetsError lists the assets that could not be found locally while
+// running offline.
+type MissingAssetsError struct {
+	Assets []string
+}
+
+func (e *MissingAssetsError) Error() string {
+	return fmt.Sprintf("%d asset(s) unavailable in offline mode:\n  - %s",
+		len(e.Assets), strings.Join(e.Assets, "\n  - "))
+}
+
+// ResolveURL rewrites rawURL to point at the mirror, if one is configured.
+// Non-network URLs such as file:// are returned unchanged.
+func (c *AssetConfig) ResolveURL(rawURL string) string {
+	if c == nil || c.Mirror == "" {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return rawURL
+	}
+
+	resolved := strings.TrimRight(c.Mirror, "/") + u.EscapedPath()
+	if u.RawQuery != "" {
+		resolved += "?" + u.RawQuery
+	}
+	return resolved
+}
+
+// Get fetches a remote asset, honoring the mirror and offline settings.
+func (c *AssetConfig) Get(rawURL string) (*http.Response, error) {
+	if c != nil && c.Offline {
+		return nil, fmt.Errorf("%s: %w", rawURL, errOffline)
+	}
+	ctx := context.Background()
+	if c != nil && c.ctx != nil {
+		ctx = c.ctx
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.ResolveURL(rawURL), nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// checkOfflineAssets verifies that every asset needed by tests is available
+// without network access.
+func checkOfflineAssets(tests []Test, vc *VideoCache) error {
+	var missing []string
+
+	for _, test := range tests {
+		if remote, ok := test.(RemoteTest); ok {
+			for _, u := range remote.RemoteURLs() {
+				missing = append(missing, fmt.Sprintf("%s: %s (loaded from network)", test.Name(), u))
+			}
+		}
+
+		if bundled, ok := test.(BundledTest); ok && !bundled.Bundle().IsCached() {
+			dir, _ := bundled.Bundle().Dir()
+			missing = append(missing, fmt.Sprintf("%s: %s (not in cache)", test.Name(), dir))
+		}
+
+		for _, video := range testVideos {
+			if video.Name == test.Name() && !vc.IsVideoCached(video) {
+				missing = append(missing, fmt.Sprintf("%s: %s (not in cache)", test.Name(), vc.GetVideoPath(video)))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return &MissingAssetsError{Assets: missing}
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// bisectCandidate is one step of a bisection: a Chrome build, or a set of
+// Chrome flags for the default build.
+type bisectCandidate struct {
+	Name       string
+	ChromePath string
+	Flags      []string
+}
+
+// chromeBinaryNames are the paths, relative to a build directory, searched for
+// the Chrome executable.
+var chromeBinaryNames = []string{
+	"chrome",
+	"chrome.exe",
+	"chrome-linux/chrome",
+	"chrome-linux64/chrome",
+	"chrome-win/chrome.exe",
+	"Chromium.app/Contents/MacOS/Chromium",
+	"chrome-mac/Chromium.app/Contents/MacOS/Chromium",
+	"Google Chrome.app/Contents/MacOS/Google Chrome",
+}
+
+func runBisect(args []string) {
+	fs := flag.NewFlagSet("bisect", flag.ExitOnError)
+	var (
+		buildsDir  = fs.String("builds", "", "Directory containing one subdirectory per Chrome build, named by revision")
+		flagSets   = fs.String("flag-sets", "", "File with one \"name: --flag ...\" Chrome flag set per line, in order, to bisect instead of builds")
+		binary     = fs.String("binary", "", "Path of the Chrome executable inside each build directory (default: auto-detect)")
+		good       = fs.String("good", "", "Name of the known good build or flag set")
+		bad        = fs.String("bad", "", "Name of the known bad build or flag set")
+		testName   = fs.String("test", "", "Test to run at each step")
+		metric     = fs.String("metric", "", "Metric to compare")
+		threshold  = fs.Float64("threshold", 0, "Metric value separating good from bad (default: midway between good and bad)")
+		repeat     = fs.Int("repeat", 3, "Number of times to run the test at each step; the median is used")
+		headless   = fs.Bool("headless", false, "Run Chrome in headless mode")
+		timeout    = fs.Duration("timeout", defaultTestTimeout, "Default timeout for each test run")
+		offline    = fs.Bool("offline", false, "Refuse all network fetches and fail if any test asset is missing")
+		mirror     = fs.String("mirror", "", "Base URL of a mirror to fetch remote test assets from")
+		requireIdl = fs.String("require-idle", IdlePolicyWarn, "Pre-flight idle policy: off, warn, wait or abort")
+	)
+	fs.Parse(args)
+
+	if (*buildsDir == "") == (*flagSets == "") {
+		log.Fatal("bisect: exactly one of -builds or -flag-sets is required")
+	}
+	if *good == "" || *bad == "" || *testName == "" || *metric == "" {
+		log.Fatal("bisect: -good, -bad, -test and -metric are required")
+	}
+	// Each step is judged by one test's result, so -test can't be a list
+	if strings.Contains(*testName, ",") {
+		log.Fatalf("bisect: -test must name exactly one test, got %q", *testName)
+	}
+	if *repeat < 1 {
+		log.Fatal("bisect: -repeat must be at least 1")
+	}
+	if !validIdlePolicy(*requireIdl) {
+		log.Fatalf("Invalid -require-idle policy %q", *requireIdl)
+	}
+	thresholdSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "threshold" {
+			thresholdSet = true
+		}
+	})
+
+	var candidates []bisectCandidate
+	var err error
+	if *buildsDir != "" {
+		candidates, err = listBuilds(*buildsDir, *binary)
+	} else {
+		candidates, err = readFlagSets(*flagSets)
+	}
+	if err != nil {
+		log.Fatalf("bisect: %v", err)
+	}
+
+	// Chrome flags after "--" apply to every step
+	for i := range candidates {
+		candidates[i].Flags = append(append([]string{}, fs.Args()...), candidates[i].Flags...)
+	}
+
+	lo, hi := indexOfCandidate(candidates, *good), indexOfCandidate(candidates, *bad)
+	if lo < 0 || hi < 0 {
+		log.Fatalf("bisect: -good %q or -bad %q not found", *good, *bad)
+	}
+	if lo >= hi {
+		log.Fatal("bisect: the good build must come before the bad build")
+	}
+	candidates = candidates[lo : hi+1]
+
+	assets := &AssetConfig{Offline: *offline, Mirror: *mirror}
+	videoCache, err := NewVideoCache(assets)
+	if err != nil {
+		log.Fatalf("Failed to initialize video cache: %v", err)
+	}
+	tests := filterTests(registerTests(assets, videoCache), *testName, "")
+	if len(tests) != 1 {
+		log.Fatalf("bisect: unknown test %q", *testName)
+	}
+	if err := prepareAssets(tests, assets, videoCache); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := interruptContext(os.Stdout)
+	defer cancel()
+
+	b := &bisector{
+		ctx: ctx,
+		harness: &TestHarness{
+			tests:      tests,
+			headless:   *headless,
+			preflight:  PreflightConfig{Policy: *requireIdl, MaxCPUPercent: 10, Timeout: 2 * time.Minute},
+			collectors: []string{},
+			isolation:  IsolateNone,
+			timeout:    *timeout,
+			out:        os.Stdout,
+		},
+		metric: *metric,
+		repeat: *repeat,
+	}
+
+	firstBad, lastGood, err := b.bisect(candidates, *threshold, thresholdSet)
+	if err != nil {
+		log.Fatalf("bisect: %v", err)
+	}
+
+	fmt.Println("=== Bisect Result ===")
+	fmt.Println()
+	fmt.Printf("Last good: %s\n", lastGood.Name)
+	fmt.Printf("First bad: %s\n", firstBad.Name)
+	if firstBad.ChromePath != "" {
+		fmt.Printf("  %s\n", firstBad.ChromePath)
+	}
+}
+
+type bisector struct {
+	ctx     context.Context
+	harness *TestHarness
+	metric  string
+	repeat  int
+	step    int
+}
+
+// bisect narrows candidates, whose first entry is good and last entry is bad,
+// down to the first bad one.
+func (b *bisector) bisect(candidates []bisectCandidate, threshold float64, thresholdSet bool) (firstBad, lastGood bisectCandidate, err error) {
+	goodValue, err := b.measure(candidates[0])
+	if err != nil {
+		return firstBad, lastGood, fmt.Errorf("measuring good build: %w", err)
+	}
+	badValue, err := b.measure(candidates[len(candidates)-1])
+	if err != nil {
+		return firstBad, lastGood, fmt.Errorf("measuring bad build: %w", err)
+	}
+	if goodValue == badValue {
+		return firstBad, lastGood, fmt.Errorf("%s is %.4g for both the good and bad builds", b.metric, goodValue)
+	}
+
+	// Work out which direction is a regression from the endpoints
+	higherIsWorse := badValue > goodValue
+	if !thresholdSet {
+		threshold = (goodValue + badValue) / 2
+	}
+	isBad := func(v float64) bool {
+		if higherIsWorse {
+			return v > threshold
+		}
+		return v < threshold
+	}
+	if isBad(goodValue) || !isBad(badValue) {
+		return firstBad, lastGood, fmt.Errorf("threshold %.4g doesn't separate good (%.4g) from bad (%.4g)", threshold, goodValue, badValue)
+	}
+	fmt.Printf("Bisecting %s: good %.4g, bad %.4g, threshold %.4g\n\n", b.metric, goodValue, badValue, threshold)
+
+	lo, hi := 0, len(candidates)-1
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		value, err := b.measure(candidates[mid])
+		if b.ctx.Err() != nil {
+			return firstBad, lastGood, b.ctx.Err()
+		}
+		if err != nil {
+			// Like git bisect skip: drop the step and try its neighbours
+			fmt.Printf("Skipping %s: %v\n\n", candidates[mid].Name, err)
+			candidates = append(candidates[:mid:mid], candidates[mid+1:]...)
+			hi--
+			continue
+		}
+
+		if isBad(value) {
+			fmt.Printf("%s: %s = %.4g (bad)\n\n", candidates[mid].Name, b.metric, value)
+			hi = mid
+		} else {
+			fmt.Printf("%s: %s = %.4g (good)\n\n", candidates[mid].Name, b.metric, value)
+			lo = mid
+		}
+	}
+
+	return candidates[hi], candidates[lo], nil
+}
+
+// measure runs the test repeat times on candidate and returns the median of
+// the metric.
+func (b *bisector) measure(candidate bisectCandidate) (float64, error) {
+	b.step++
+	fmt.Printf("=== Step %d: %s ===\n\n", b.step, candidate.Name)
+
+	b.harness.execPath = candidate.ChromePath
+	b.harness.chromeFlags = candidate.Flags
+
+	var values []float64
+	for i := 0; i < b.repeat; i++ {
+		run, err := b.harness.RunTests(b.ctx)
+		if err != nil {
+			return 0, err
+		}
+		if run.Interrupted {
+			return 0, b.ctx.Err()
+		}
+		if len(run.Results) == 0 {
+			return 0, fmt.Errorf("no results")
+		}
+
+		result := run.Results[0]
+		if !result.Success {
+			return 0, fmt.Errorf("test failed: %v", result.Error)
+		}
+		value, ok := numericValue(result.Metrics[b.metric])
+		if !ok {
+			return 0, fmt.Errorf("metric %s not reported", b.metric)
+		}
+		values = append(values, value)
+	}
+
+	return median(values), nil
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func indexOfCandidate(candidates []bisectCandidate, name string) int {
+	for i, c := range candidates {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// listBuilds returns the Chrome builds in dir sorted by revision.
+func listBuilds(dir, binary string) ([]bisectCandidate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var builds []bisectCandidate
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		buildDir := filepath.Join(dir, entry.Name())
+		path := findChromeBinary(buildDir, binary)
+		if path == "" {
+			continue
+		}
+		builds = append(builds, bisectCandidate{Name: entry.Name(), ChromePath: path})
+	}
+	if len(builds) == 0 {
+		return nil, fmt.Errorf("no Chrome builds found in %s", dir)
+	}
+
+	sort.Slice(builds, func(i, j int) bool {
+		return naturalLess(builds[i].Name, builds[j].Name)
+	})
+	return builds, nil
+}
+
+func findChromeBinary(buildDir, binary string) string {
+	names := chromeBinaryNames
+	if binary != "" {
+		names = []string{binary}
+	}
+	for _, name := range names {
+		path := filepath.Join(buildDir, filepath.FromSlash(name))
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() && (runtime.GOOS == "windows" || info.Mode()&0111 != 0) {
+			return path
+		}
+	}
+	return ""
+}
+
+// readFlagSets parses "name: --flag --flag" lines. Blank lines and lines
+// starting with # are ignored.
+func readFlagSets(path string) ([]bisectCandidate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sets []bisectCandidate
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, flags, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid flag set %q, expected \"name: --flag ...\"", line)
+		}
+		sets = append(sets, bisectCandidate{
+			Name:  strings.TrimSpace(name),
+			Flags: strings.Fields(flags),
+		})
+	}
+	return sets, scanner.Err()
+}
+
+// naturalLess compares strings treating runs of digits as numbers, so that
+// revision "99" sorts before "100" and "120.0.6099.5" before "120.0.6099.10".
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ra, rb := rune(a[0]), rune(b[0])
+		if unicode.IsDigit(ra) && unicode.IsDigit(rb) {
+			na, restA := leadingNumber(a)
+			nb, restB := leadingNumber(b)
+			if na != nb {
+				return na < nb
+			}
+			a, b = restA, restB
+			continue
+		}
+		if ra != rb {
+			return ra < rb
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingNumber(s string) (uint64, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, _ := strconv.ParseUint(s[:i], 10, 64)
+	return n, s[i:]
+}
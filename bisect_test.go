@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"99", "100", true},
+		{"100", "99", false},
+		{"120.0.6099.5", "120.0.6099.10", true},
+		{"120.0.6099.10", "120.0.6099.5", false},
+		{"119.0.6045.199", "120.0.6099.5", true},
+		{"chrome-9", "chrome-10", true},
+		{"a", "b", true},
+		{"b", "a", false},
+		{"abc", "abcd", true},
+		{"abcd", "abc", false},
+		{"", "1", true},
+		{"1", "", false},
+		{"same", "same", false},
+		{"7", "007", false},
+		{"007", "7", false},
+		{"r1a", "r1b", true},
+	}
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/cdproto/systeminfo"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// Isolation modes accepted by -isolate.
+const (
+	IsolateNone    = "none"
+	IsolateTab     = "tab"
+	IsolateBrowser = "browser"
+)
+
+func validIsolation(mode string) bool {
+	switch mode {
+	case IsolateNone, IsolateTab, IsolateBrowser:
+		return true
+	}
+	return false
+}
+
+// browserSession is a running Chrome instance and the tab tests run in. It
+// watches for renderer, GPU and browser process crashes so a dead browser
+// isn't reused for the following tests.
+type browserSession struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	userDataDir string
+
+	// remote is set when attached with -remote. localPID is the pid of the
+	// remote browser if it runs on this host, otherwise 0.
+	remote   bool
+	localPID int
+
+	mu          sync.Mutex
+	targets     map[target.ID]bool
+	closing     bool
+	crashReason string
+	onCrash     context.CancelFunc
+}
+
+// chromeOptions returns the allocator options for launching Chrome with the
+// harness's flags.
+func (h *TestHarness) chromeOptions() []chromedp.ExecAllocatorOption {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", h.headless),
+		chromedp.Flag("disable-blink-features", "AutomationControlled"),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.ModifyCmdFunc(detachProcessGroup),
+	)
+
+	if h.execPath != "" {
+		opts = append(opts, chromedp.ExecPath(h.execPath))
+	}
+
+	// Add custom Chrome flags
+	for _, flag := range h.chromeFlags {
+		// Remove leading dashes if present
+		flag = strings.TrimLeft(flag, "-")
+
+		parts := strings.SplitN(flag, "=", 2)
+		if len(parts) == 2 {
+			opts = append(opts, chromedp.Flag(parts[0], parts[1]))
+		} else {
+			opts = append(opts, chromedp.Flag(parts[0], true))
+		}
+	}
+
+	return opts
+}
+
+// launchBrowser starts Chrome with a fresh temporary profile and opens the
+// tab tests will run in.
+func (h *TestHarness) launchBrowser() (*browserSession, error) {
+	if h.remoteURL != "" {
+		return h.connectRemote()
+	}
+
+	userDataDir, err := os.MkdirTemp("", "chromebench-profile-*")
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append(h.chromeOptions(), chromedp.UserDataDir(userDataDir))
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	ctx, cancelCtx := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
+
+	b := &browserSession{
+		ctx:         ctx,
+		userDataDir: userDataDir,
+		targets:     make(map[target.ID]bool),
+		cancel: func() {
+			cancelCtx()
+			cancelAlloc()
+		},
+	}
+
+	// Start the browser so the crash listeners can be attached
+	if err := chromedp.Run(ctx); err != nil {
+		b.Close()
+		return nil, err
+	}
+	b.watchCrashes()
+
+	return b, nil
+}
+
+func (b *browserSession) watchCrashes() {
+	c := chromedp.FromContext(b.ctx)
+	b.watchTarget(b.ctx)
+
+	chromedp.ListenBrowser(b.ctx, func(ev interface{}) {
+		if ev, ok := ev.(*target.EventTargetCrashed); ok && b.isWatched(ev.TargetID) {
+			b.crashed(fmt.Sprintf("renderer %s (code %d)", ev.Status, ev.ErrorCode))
+		}
+	})
+
+	go func() {
+		select {
+		case <-c.Browser.LostConnection:
+			b.crashed("browser process exited")
+		case <-b.ctx.Done():
+		}
+	}()
+}
+
+// watchTarget listens for crashes of the tab behind ctx.
+func (b *browserSession) watchTarget(ctx context.Context) {
+	b.mu.Lock()
+	b.targets[chromedp.FromContext(ctx).Target.TargetID] = true
+	b.mu.Unlock()
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *inspector.EventTargetCrashed:
+			b.crashed("renderer crashed")
+		case *inspector.EventDetached:
+			// Closing an isolated tab detaches it too
+			if ctx.Err() == nil {
+				b.crashed(fmt.Sprintf("target detached: %s", ev.Reason))
+			}
+		}
+	})
+}
+
+func (b *browserSession) isWatched(id target.ID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.targets[id]
+}
+
+// newTab opens a new tab in the browser for a single test. Cancelling the
+// returned context closes the tab.
+func (b *browserSession) newTab() (context.Context, context.CancelFunc, error) {
+	ctx, cancel := chromedp.NewContext(b.ctx)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	b.watchTarget(ctx)
+	return ctx, cancel, nil
+}
+
+// crashed records the first crash reason and cancels the running test.
+func (b *browserSession) crashed(reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closing {
+		return
+	}
+	if b.crashReason == "" {
+		b.crashReason = reason
+	}
+	if b.onCrash != nil {
+		b.onCrash()
+	}
+}
+
+// watch arranges for cancel to be called if the browser crashes. Pass nil once
+// the test has finished.
+func (b *browserSession) watch(cancel context.CancelFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onCrash = cancel
+}
+
+// CrashReason returns why the browser crashed, or "" if it hasn't.
+func (b *browserSession) CrashReason() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.crashReason
+}
+
+// gpuProcessID returns the pid of the GPU process, or 0 if it can't be
+// determined. A change in pid between two calls means the GPU process crashed
+// and was restarted. A browser that doesn't answer within 5 seconds counts as
+// undetermined rather than holding up the run.
+func (b *browserSession) gpuProcessID() int64 {
+	var pid int64
+	chromedp.Run(b.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		c := chromedp.FromContext(ctx)
+		processes, err := systeminfo.GetProcessInfo().Do(cdp.WithExecutor(ctx, c.Browser))
+		if err != nil {
+			return err
+		}
+		for _, p := range processes {
+			if p.Type == "GPU" {
+				pid = p.ID
+			}
+		}
+		return nil
+	}))
+	return pid
+}
+
+// collectMinidumps copies crash dumps written to the profile since the given
+// time into ~/.chromebench/crashes, returning their new paths.
+func (b *browserSession) collectMinidumps(since time.Time) []string {
+	if b.userDataDir == "" {
+		return nil
+	}
+
+	var dumps []string
+	filepath.Walk(b.userDataDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, ".dmp") && info.ModTime().After(since) {
+			dumps = append(dumps, path)
+		}
+		return nil
+	})
+	if len(dumps) == 0 {
+		return nil
+	}
+
+	crashDir, err := chromebenchDir("crashes")
+	if err != nil {
+		return dumps
+	}
+
+	var saved []string
+	for _, dump := range dumps {
+		dest := filepath.Join(crashDir, fmt.Sprintf("%s-%s", since.Format("20060102-150405"), filepath.Base(dump)))
+		if err := copyFile(dump, dest); err != nil {
+			log.Printf("Failed to save minidump %s: %v", dump, err)
+			continue
+		}
+		saved = append(saved, dest)
+	}
+	return saved
+}
+
+// Close shuts down the browser and removes its temporary profile. A remote
+// browser is left running with only our tab closed.
+func (b *browserSession) Close() {
+	b.mu.Lock()
+	b.closing = true
+	b.mu.Unlock()
+
+	b.cancel()
+	if b.userDataDir != "" {
+		os.RemoveAll(b.userDataDir)
+	}
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BenchmarkBundle is a pinned release of a browser benchmark, downloaded once
+// as a zip archive into ~/.chromebench/bundles/<Name> and served from a local
+// HTTP server so results don't depend on browserbench.org.
+type BenchmarkBundle struct {
+	Name   string
+	URL    string
+	assets *AssetConfig
+}
+
+// BundledTest is implemented by tests that run a BenchmarkBundle.
+type BundledTest interface {
+	Bundle() *BenchmarkBundle
+}
+
+// Dir returns where the bundle is extracted.
+func (b *BenchmarkBundle) Dir() (string, error) {
+	dir, err := chromebenchDir("bundles")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, b.Name), nil
+}
+
+func (b *BenchmarkBundle) IsCached() bool {
+	dir, err := b.Dir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(dir)
+	return err == nil
+}
+
+// Ensure downloads and extracts the bundle if it isn't cached yet.
+func (b *BenchmarkBundle) Ensure() error {
+	if b.IsCached() {
+		return nil
+	}
+	if b.assets.Offline {
+		return fmt.Errorf("%s: %w", b.Name, errOffline)
+	}
+
+	dir, err := b.Dir()
+	if err != nil {
+		return err
+	}
+
+	out := b.assets.output()
+	fmt.Fprintf(out, "Downloading %s from %s...\n", b.Name, b.assets.ResolveURL(b.URL))
+	resp, err := b.assets.Get(b.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	// zip needs random access, so download to a temporary file first
+	archive, err := os.CreateTemp("", b.Name+"-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	pr := &progressReader{
+		Reader: resp.Body,
+		Total:  resp.ContentLength,
+		Name:   b.Name,
+		Out:    out,
+	}
+	size, err := io.Copy(archive, pr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+
+	// Extract next to the final directory and rename, so an interrupted
+	// download never looks cached
+	tmpDir := dir + ".tmp"
+	os.RemoveAll(tmpDir)
+	if err := extractZip(archive, size, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("extracting %s: %w", b.Name, err)
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+
+	fmt.Fprintf(out, "Cached %s in %s\n", b.Name, dir)
+	return nil
+}
+
+// extractZip extracts the archive into dir, dropping the single top-level
+// directory GitHub source archives have.
+func extractZip(r io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		name := f.Name
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		if name == "" {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("%s: path outside archive", f.Name)
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractZipFile(f, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Serve serves the extracted bundle on a free localhost port and returns its
+// base URL and a function that stops the server.
+func (b *BenchmarkBundle) Serve() (string, func(), error) {
+	dir, err := b.Dir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	server := &http.Server{Handler: http.FileServer(http.Dir(dir))}
+	go server.Serve(listener)
+
+	return "http://" + listener.Addr().String(), func() { server.Close() }, nil
+}
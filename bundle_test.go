@@ -0,0 +1,88 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractZip(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "strips top-level directory",
+			entries: []string{"Speedometer-3.0/", "Speedometer-3.0/index.html", "Speedometer-3.0/resources/main.js"},
+			want:    []string{"index.html", "resources/main.js"},
+		},
+		{
+			name:    "dot dot inside archive",
+			entries: []string{"top/a/../index.html"},
+			want:    []string{"index.html"},
+		},
+		{
+			name:    "parent directory",
+			entries: []string{"top/../evil.txt"},
+			wantErr: true,
+		},
+		{
+			name:    "nested parent directory",
+			entries: []string{"top/a/../../../evil.txt"},
+			wantErr: true,
+		},
+		{
+			name:    "top-level dot dot",
+			entries: []string{"top/.."},
+			wantErr: true,
+		},
+		{
+			name:    "sibling with bundle name as prefix",
+			entries: []string{"top/../bundle-evil/evil.txt"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, name := range tt.entries {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name[len(name)-1] != '/' {
+				w.Write([]byte(name))
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		root := t.TempDir()
+		dir := filepath.Join(root, "bundle")
+		err := extractZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), dir)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: extractZip succeeded, want error", tt.name)
+			}
+			matches, _ := filepath.Glob(filepath.Join(root, "*evil*"))
+			if len(matches) > 0 {
+				t.Errorf("%s: wrote outside the bundle: %v", tt.name, matches)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: extractZip: %v", tt.name, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(want))); err != nil {
+				t.Errorf("%s: %s not extracted: %v", tt.name, want, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ChromeBinary is a browser build selected with -chrome.
+type ChromeBinary struct {
+	Label string
+	Path  string
+}
+
+// chromeBinariesFlag collects repeated -chrome [label=]path flags.
+type chromeBinariesFlag []ChromeBinary
+
+func (f *chromeBinariesFlag) String() string {
+	var parts []string
+	for _, b := range *f {
+		parts = append(parts, b.Label+"="+b.Path)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *chromeBinariesFlag) Set(value string) error {
+	label, path, ok := strings.Cut(value, "=")
+	// Only treat the prefix as a label if it isn't itself part of a path
+	if !ok || strings.ContainsAny(label, `/\`) {
+		label, path = value, value
+	}
+	if path == "" {
+		return fmt.Errorf("empty Chrome path in %q", value)
+	}
+
+	for _, b := range *f {
+		if b.Label == label {
+			return fmt.Errorf("duplicate Chrome label %q", label)
+		}
+	}
+	*f = append(*f, ChromeBinary{Label: label, Path: path})
+	return nil
+}
+
+// comparisonRow is one line of the comparison table: a test heading when
+// Metric is empty, otherwise a metric with its value for each run ("-" if
+// missing).
+type comparisonRow struct {
+	Test   string
+	Metric string
+	Values []string
+}
+
+// comparisonRows lines up every numeric metric of each test across runs, with
+// tests in the order they were first run and metrics sorted alphabetically.
+func comparisonRows(runs []*RunResult) []comparisonRow {
+	// Tests in the order they were first run
+	var testNames []string
+	seen := make(map[string]bool)
+	for _, run := range runs {
+		for _, result := range run.Results {
+			if !seen[result.TestName] {
+				seen[result.TestName] = true
+				testNames = append(testNames, result.TestName)
+			}
+		}
+	}
+
+	var rows []comparisonRow
+	for _, name := range testNames {
+		rows = append(rows, comparisonRow{Test: name})
+
+		results := make([]*TestResult, len(runs))
+		keySet := make(map[string]bool)
+		for i, run := range runs {
+			for j := range run.Results {
+				if run.Results[j].TestName == name {
+					results[i] = &run.Results[j]
+					for key, value := range run.Results[j].Metrics {
+						if _, ok := numericValue(value); ok {
+							keySet[key] = true
+						}
+					}
+				}
+			}
+		}
+
+		// Sort keys alphabetically
+		keys := make([]string, 0, len(keySet))
+		for key := range keySet {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			row := comparisonRow{Test: name, Metric: key}
+			for _, result := range results {
+				value, ok := 0.0, false
+				if result != nil {
+					value, ok = numericValue(result.Metrics[key])
+				}
+				if ok {
+					row.Values = append(row.Values, fmt.Sprintf("%.2f", value))
+				} else {
+					row.Values = append(row.Values, "-")
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// printComparison prints every numeric metric side by side for each browser
+// build that was run.
+func printComparison(out io.Writer, runs []*RunResult) {
+	fmt.Fprintln(out, "=== Comparison ===")
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := "Test / Metric"
+	for _, run := range runs {
+		header += "\t" + run.Label
+	}
+	fmt.Fprintln(w, header+"\t")
+
+	for _, row := range comparisonRows(runs) {
+		if row.Metric == "" {
+			fmt.Fprintf(w, "%s\t", row.Test)
+			for range runs {
+				fmt.Fprint(w, "\t")
+			}
+			fmt.Fprintln(w)
+			continue
+		}
+		fmt.Fprintf(w, "  %s", row.Metric)
+		for _, value := range row.Values {
+			fmt.Fprintf(w, "\t%s", value)
+		}
+		fmt.Fprintln(w, "\t")
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+}
+
+// numericValue returns v as a float64 if it holds a number.
+func numericValue(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64, int, int64:
+		return getFloat64(val), true
+	default:
+		return 0, false
+	}
+}
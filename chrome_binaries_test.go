@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChromeBinariesFlagSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    chromeBinariesFlag
+		wantErr bool
+	}{
+		{
+			name:   "labelled",
+			values: []string{"stable=/opt/chrome/chrome", "canary=/opt/canary/chrome"},
+			want:   chromeBinariesFlag{{"stable", "/opt/chrome/chrome"}, {"canary", "/opt/canary/chrome"}},
+		},
+		{
+			name:   "path only",
+			values: []string{"/usr/bin/chromium"},
+			want:   chromeBinariesFlag{{"/usr/bin/chromium", "/usr/bin/chromium"}},
+		},
+		{
+			name:   "equals inside path",
+			values: []string{"/builds/rev=1234/chrome"},
+			want:   chromeBinariesFlag{{"/builds/rev=1234/chrome", "/builds/rev=1234/chrome"}},
+		},
+		{
+			name:   "windows path",
+			values: []string{`C:\a=b\chrome.exe`},
+			want:   chromeBinariesFlag{{`C:\a=b\chrome.exe`, `C:\a=b\chrome.exe`}},
+		},
+		{
+			name:   "label with equals in path",
+			values: []string{"dev=/builds/rev=1234/chrome"},
+			want:   chromeBinariesFlag{{"dev", "/builds/rev=1234/chrome"}},
+		},
+		{
+			name:    "empty path",
+			values:  []string{"stable="},
+			wantErr: true,
+		},
+		{
+			name:    "empty value",
+			values:  []string{""},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate label",
+			values:  []string{"stable=/a/chrome", "stable=/b/chrome"},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate path",
+			values:  []string{"/a/chrome", "/a/chrome"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		var f chromeBinariesFlag
+		var err error
+		for _, v := range tt.values {
+			if err = f.Set(v); err != nil {
+				break
+			}
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Set(%q) = %v, want error", tt.name, tt.values, f)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Set(%q): %v", tt.name, tt.values, err)
+			continue
+		}
+		if !reflect.DeepEqual(f, tt.want) {
+			t.Errorf("%s: Set(%q) = %v, want %v", tt.name, tt.values, f, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sample is a single timestamped measurement recorded by a Collector.
+type Sample struct {
+	Timestamp time.Time
+	Values    map[string]float64
+}
+
+// Collector gathers measurements while a test is running. A new Collector is
+// created for every test.
+type Collector interface {
+	Name() string
+	// Start begins collecting. ctx is the test's browser context.
+	Start(ctx context.Context) error
+	Stop()
+	// Results returns the raw samples and the summary metrics to merge into
+	// TestResult.Metrics. It is only valid after Stop.
+	Results() ([]Sample, map[string]interface{})
+}
+
+// collectorRegistry maps the names accepted by -collectors to constructors.
+// Each constructor is given the browser the test will run in.
+var collectorRegistry = map[string]func(b *browserSession) Collector{
+	"cpu":     func(b *browserSession) Collector { return newChromeCPUMonitorFor(b) },
+	"thermal": func(b *browserSession) Collector { return NewThermalMonitor() },
+	"memory":  func(b *browserSession) Collector { return newMemoryMonitorFor(b) },
+	"power":   func(b *browserSession) Collector { return NewPowerMonitor() },
+
+	"performance": func(b *browserSession) Collector { return NewPerformanceCollector() },
+}
+
+var defaultCollectors = []string{"cpu", "thermal", "performance"}
+
+func availableCollectors() []string {
+	names := make([]string, 0, len(collectorRegistry))
+	for name := range collectorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseCollectors validates a comma-separated list of collector names.
+func parseCollectors(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := collectorRegistry[name]; !ok {
+			return nil, fmt.Errorf("unknown collector %q (available: %s)", name, strings.Join(availableCollectors(), ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// startCollectors creates and starts the named collectors, skipping any that
+// are not supported on this host.
+func startCollectors(ctx context.Context, out io.Writer, names []string, b *browserSession) []Collector {
+	var started []Collector
+	for _, name := range names {
+		c := collectorRegistry[name](b)
+		if err := c.Start(ctx); err != nil {
+			fmt.Fprintf(out, "  Collector %s unavailable: %v\n", name, err)
+			continue
+		}
+		started = append(started, c)
+	}
+	return started
+}
+
+// poller calls sample every interval between start and stop, recording each
+// successful result. It is shared by collectors that only need periodic
+// sampling.
+type poller struct {
+	interval time.Duration
+	sample   func() (map[string]float64, error)
+	samples  []Sample
+	mu       sync.Mutex
+	stop     chan bool
+	wg       sync.WaitGroup
+}
+
+func newPoller(interval time.Duration, sample func() (map[string]float64, error)) *poller {
+	return &poller{
+		interval: interval,
+		sample:   sample,
+		stop:     make(chan bool),
+	}
+}
+
+func (p *poller) start() {
+	p.wg.Add(1)
+	go p.run()
+}
+
+func (p *poller) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+func (p *poller) getSamples() []Sample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]Sample, len(p.samples))
+	copy(result, p.samples)
+	return result
+}
+
+func (p *poller) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			values, err := p.sample()
+			if err == nil {
+				p.mu.Lock()
+				p.samples = append(p.samples, Sample{Timestamp: time.Now(), Values: values})
+				p.mu.Unlock()
+			}
+		}
+	}
+}
+
+// summarizeSamples returns the average and maximum of key across samples.
+func summarizeSamples(samples []Sample, key string) (avg, max float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	var total float64
+	for _, s := range samples {
+		v := s.Values[key]
+		total += v
+		if v > max {
+			max = v
+		}
+	}
+	return total / float64(len(samples)), max
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os/exec"
 	"runtime"
@@ -17,6 +18,14 @@ type ChromeCPUMonitor struct {
 	stop     chan bool
 	wg       sync.WaitGroup
 	interval time.Duration
+
+	// rootPID, if set, limits monitoring to that process and its
+	// descendants instead of every Chrome-looking process. unavailable is
+	// the reason monitoring can't run at all.
+	rootPID     int
+	unavailable error
+	lastTicks   map[int]processCPUTime
+	lastTime    time.Time
 }
 
 func NewChromeCPUMonitor() *ChromeCPUMonitor {
@@ -26,9 +35,30 @@ func NewChromeCPUMonitor() *ChromeCPUMonitor {
 	}
 }
 
-func (m *ChromeCPUMonitor) Start() {
+// newChromeCPUMonitorFor returns a monitor for the browser in b. A remote
+// browser is only monitored when it runs on this host.
+func newChromeCPUMonitorFor(b *browserSession) *ChromeCPUMonitor {
+	m := NewChromeCPUMonitor()
+	if b.remote {
+		m.rootPID = b.localPID
+		if m.rootPID == 0 {
+			m.unavailable = fmt.Errorf("remote browser is not on this host")
+		}
+	}
+	return m
+}
+
+func (m *ChromeCPUMonitor) Name() string {
+	return "cpu"
+}
+
+func (m *ChromeCPUMonitor) Start(ctx context.Context) error {
+	if m.unavailable != nil {
+		return m.unavailable
+	}
 	m.wg.Add(1)
 	go m.monitor()
+	return nil
 }
 
 func (m *ChromeCPUMonitor) Stop() {
@@ -45,6 +75,18 @@ func (m *ChromeCPUMonitor) GetSamples() []CPUSample {
 	return result
 }
 
+func (m *ChromeCPUMonitor) Results() ([]Sample, map[string]interface{}) {
+	cpuSamples := m.GetSamples()
+	samples := make([]Sample, len(cpuSamples))
+	for i, s := range cpuSamples {
+		samples[i] = Sample{
+			Timestamp: s.Timestamp,
+			Values:    map[string]float64{"usage_percent": s.Usage},
+		}
+	}
+	return samples, nil
+}
+
 func (m *ChromeCPUMonitor) monitor() {
 	defer m.wg.Done()
 	
@@ -70,6 +112,10 @@ func (m *ChromeCPUMonitor) monitor() {
 }
 
 func (m *ChromeCPUMonitor) getChromeCPUUsage() (float64, error) {
+	if m.rootPID != 0 {
+		return m.getProcessTreeCPUUsage()
+	}
+
 	switch runtime.GOOS {
 	case "darwin":
 		return m.getChromeCPUUsageDarwin()
@@ -82,6 +128,56 @@ func (m *ChromeCPUMonitor) getChromeCPUUsage() (float64, error) {
 	}
 }
 
+// getProcessTreeCPUUsage sums the CPU usage of rootPID and its descendants.
+func (m *ChromeCPUMonitor) getProcessTreeCPUUsage() (float64, error) {
+	tree, err := processTree(m.rootPID)
+	if err != nil {
+		return 0, err
+	}
+
+	if runtime.GOOS != "linux" {
+		// ps reports a recent average for each process
+		output, err := exec.Command("ps", "-A", "-o", "pid=,%cpu=").Output()
+		if err != nil {
+			return 0, err
+		}
+		var totalCPU float64
+		scanner := bufio.NewScanner(strings.NewReader(string(output)))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			pid, _ := strconv.Atoi(fields[0])
+			if tree[pid] {
+				cpu, err := strconv.ParseFloat(fields[1], 64)
+				if err == nil {
+					totalCPU += cpu
+				}
+			}
+		}
+		return totalCPU, nil
+	}
+
+	// On Linux, diff the tree's CPU ticks since the previous sample
+	now := time.Now()
+	ticks := readProcessCPUTimes()
+	last, lastTime := m.lastTicks, m.lastTime
+	m.lastTicks, m.lastTime = ticks, now
+	if last == nil {
+		return 0, fmt.Errorf("no previous sample")
+	}
+
+	// USER_HZ is 100 on all mainstream Linux architectures
+	var used uint64
+	for pid := range tree {
+		if cur, ok := ticks[pid]; ok && cur.ticks >= last[pid].ticks {
+			used += cur.ticks - last[pid].ticks
+		}
+	}
+	return float64(used) / 100 / now.Sub(lastTime).Seconds() * 100, nil
+}
+
 func (m *ChromeCPUMonitor) getChromeCPUUsageDarwin() (float64, error) {
 	// Get all Chrome-related processes
 	cmd := exec.Command("ps", "-A", "-o", "pid,ppid,%cpu,command")
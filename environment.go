@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/systeminfo"
+	"github.com/chromedp/chromedp"
+)
+
+// EnvironmentInfo describes the browser, GPU and host a run was executed on.
+type EnvironmentInfo struct {
+	Browser     string
+	Revision    string
+	UserAgent   string
+	JSVersion   string
+	CommandLine []string
+
+	MachineModel         string
+	GPUDevices           []GPUDevice
+	FeatureStatus        map[string]string
+	DriverBugWorkarounds []string
+	VideoDecoding        []VideoCodecCapability
+	VideoEncoding        []VideoCodecCapability
+
+	OS          string
+	OSVersion   string
+	Kernel      string
+	Arch        string
+	CPUModel    string
+	CPUCores    int
+	MemoryBytes uint64
+}
+
+type GPUDevice struct {
+	VendorID      uint32
+	DeviceID      uint32
+	Vendor        string
+	Device        string
+	DriverVendor  string
+	DriverVersion string
+}
+
+func (d GPUDevice) String() string {
+	return fmt.Sprintf("%s %s (0x%04x:0x%04x), driver %s %s", d.Vendor, d.Device, d.VendorID, d.DeviceID, d.DriverVendor, d.DriverVersion)
+}
+
+// VideoCodecCapability is a hardware accelerated decode or encode profile
+// reported by the GPU process.
+type VideoCodecCapability struct {
+	Profile       string
+	MinResolution string
+	MaxResolution string
+	MaxFramerate  float64
+}
+
+// CaptureEnvironment collects host information and queries the browser behind
+// ctx for its version, command line and GPU capabilities. Host information is
+// always returned, even if querying the browser fails.
+func CaptureEnvironment(ctx context.Context) (*EnvironmentInfo, error) {
+	env := &EnvironmentInfo{}
+	env.captureHost()
+
+	var gpu *systeminfo.GPUInfo
+	err := chromedp.Run(ctx,
+		chromedp.Navigate("about:blank"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			c := chromedp.FromContext(ctx)
+			browserCtx := cdp.WithExecutor(ctx, c.Browser)
+			var err error
+			_, env.Browser, env.Revision, env.UserAgent, env.JSVersion, err = browser.GetVersion().Do(browserCtx)
+			if err != nil {
+				return err
+			}
+			env.CommandLine, err = browser.GetBrowserCommandLine().Do(browserCtx)
+			if err != nil {
+				return err
+			}
+			gpu, env.MachineModel, _, _, err = systeminfo.GetInfo().Do(browserCtx)
+			return err
+		}),
+	)
+	if err != nil {
+		return env, err
+	}
+
+	if gpu != nil {
+		env.captureGPU(gpu)
+	}
+	return env, nil
+}
+
+func (env *EnvironmentInfo) captureGPU(gpu *systeminfo.GPUInfo) {
+	for _, d := range gpu.Devices {
+		env.GPUDevices = append(env.GPUDevices, GPUDevice{
+			VendorID:      uint32(d.VendorID),
+			DeviceID:      uint32(d.DeviceID),
+			Vendor:        d.VendorString,
+			Device:        d.DeviceString,
+			DriverVendor:  d.DriverVendor,
+			DriverVersion: d.DriverVersion,
+		})
+	}
+
+	if gpu.FeatureStatus != nil {
+		var featureStatus map[string]string
+		if err := json.Unmarshal(gpu.FeatureStatus, &featureStatus); err == nil {
+			env.FeatureStatus = featureStatus
+		}
+	}
+
+	env.DriverBugWorkarounds = gpu.DriverBugWorkarounds
+
+	for _, c := range gpu.VideoDecoding {
+		env.VideoDecoding = append(env.VideoDecoding, VideoCodecCapability{
+			Profile:       c.Profile,
+			MinResolution: formatSize(c.MinResolution),
+			MaxResolution: formatSize(c.MaxResolution),
+		})
+	}
+	for _, c := range gpu.VideoEncoding {
+		capability := VideoCodecCapability{
+			Profile:       c.Profile,
+			MaxResolution: formatSize(c.MaxResolution),
+		}
+		if c.MaxFramerateDenominator > 0 {
+			capability.MaxFramerate = float64(c.MaxFramerateNumerator) / float64(c.MaxFramerateDenominator)
+		}
+		env.VideoEncoding = append(env.VideoEncoding, capability)
+	}
+}
+
+func formatSize(size *systeminfo.Size) string {
+	if size == nil {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", size.Width, size.Height)
+}
+
+func (env *EnvironmentInfo) captureHost() {
+	env.OS = runtime.GOOS
+	env.Arch = runtime.GOARCH
+	env.CPUCores = runtime.NumCPU()
+
+	switch runtime.GOOS {
+	case "linux":
+		env.captureHostLinux()
+	case "darwin":
+		env.captureHostDarwin()
+	case "windows":
+		env.captureHostWindows()
+	}
+}
+
+func (env *EnvironmentInfo) captureHostLinux() {
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		env.Kernel = strings.TrimSpace(string(data))
+	}
+
+	if values, err := readKeyValueFile("/etc/os-release", "="); err == nil {
+		env.OSVersion = strings.Trim(values["PRETTY_NAME"], `"`)
+	}
+
+	if values, err := readKeyValueFile("/proc/cpuinfo", ":"); err == nil {
+		// x86 reports "model name"; many ARM kernels only report "Hardware"
+		for _, key := range []string{"model name", "Hardware", "Model"} {
+			if v := values[key]; v != "" {
+				env.CPUModel = v
+				break
+			}
+		}
+	}
+
+	if values, err := readKeyValueFile("/proc/meminfo", ":"); err == nil {
+		fields := strings.Fields(values["MemTotal"])
+		if len(fields) > 0 {
+			if kb, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+				env.MemoryBytes = kb * 1024
+			}
+		}
+	}
+}
+
+func (env *EnvironmentInfo) captureHostDarwin() {
+	env.OSVersion = commandOutput("sw_vers", "-productVersion")
+	env.Kernel = commandOutput("uname", "-r")
+	env.CPUModel = commandOutput("sysctl", "-n", "machdep.cpu.brand_string")
+	if mem, err := strconv.ParseUint(commandOutput("sysctl", "-n", "hw.memsize"), 10, 64); err == nil {
+		env.MemoryBytes = mem
+	}
+}
+
+func (env *EnvironmentInfo) captureHostWindows() {
+	env.OSVersion = wmicValue("os", "Caption")
+	env.Kernel = wmicValue("os", "Version")
+	env.CPUModel = wmicValue("cpu", "Name")
+	if mem, err := strconv.ParseUint(wmicValue("ComputerSystem", "TotalPhysicalMemory"), 10, 64); err == nil {
+		env.MemoryBytes = mem
+	}
+}
+
+// readKeyValueFile parses "key<sep>value" lines, keeping the first value seen
+// for each key.
+func readKeyValueFile(path, sep string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), sep, 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		if _, ok := values[key]; !ok {
+			values[key] = strings.TrimSpace(parts[1])
+		}
+	}
+	return values, scanner.Err()
+}
+
+func commandOutput(name string, args ...string) string {
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func wmicValue(alias, property string) string {
+	output := commandOutput("wmic", alias, "get", property, "/value")
+	for _, line := range strings.Split(output, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), property+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Print writes a human readable description of the environment to w.
+func (env *EnvironmentInfo) Print(w io.Writer) {
+	fmt.Fprintf(w, "Browser: %s (%s)\n\n", env.Browser, env.Revision)
+	fmt.Fprintf(w, "Commandline: %v\n\n", env.CommandLine)
+
+	fmt.Fprintln(w, "Host Information:")
+	fmt.Fprintf(w, "  OS: %s %s (%s)\n", env.OS, env.OSVersion, env.Arch)
+	if env.Kernel != "" {
+		fmt.Fprintf(w, "  Kernel: %s\n", env.Kernel)
+	}
+	if env.MachineModel != "" {
+		fmt.Fprintf(w, "  Model: %s\n", env.MachineModel)
+	}
+	fmt.Fprintf(w, "  CPU: %s (%d cores)\n", env.CPUModel, env.CPUCores)
+	if env.MemoryBytes > 0 {
+		fmt.Fprintf(w, "  Memory: %.1f GB\n", float64(env.MemoryBytes)/(1024*1024*1024))
+	}
+
+	fmt.Fprintln(w, "\nGPU Information:")
+	if len(env.GPUDevices) > 0 {
+		for i, d := range env.GPUDevices {
+			fmt.Fprintf(w, "  GPU %d:\n", i)
+			fmt.Fprintf(w, "    Vendor: %s (0x%04x)\n", d.Vendor, d.VendorID)
+			fmt.Fprintf(w, "    Device: %s (0x%04x)\n", d.Device, d.DeviceID)
+			fmt.Fprintf(w, "    Driver: %s %s\n", d.DriverVendor, d.DriverVersion)
+		}
+	} else {
+		fmt.Fprintf(w, "  GPU devices not available\n")
+	}
+
+	if len(env.FeatureStatus) > 0 {
+		fmt.Fprintln(w, "\nGPU Feature Status:")
+		// Sort feature keys alphabetically
+		keys := make([]string, 0, len(env.FeatureStatus))
+		for k := range env.FeatureStatus {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, feature := range keys {
+			fmt.Fprintf(w, "  %s: %s\n", feature, env.FeatureStatus[feature])
+		}
+	}
+
+	if len(env.VideoDecoding) > 0 {
+		fmt.Fprintln(w, "\nHardware Video Decode:")
+		for _, c := range env.VideoDecoding {
+			fmt.Fprintf(w, "  %s: %s - %s\n", c.Profile, c.MinResolution, c.MaxResolution)
+		}
+	}
+
+	if len(env.VideoEncoding) > 0 {
+		fmt.Fprintln(w, "\nHardware Video Encode:")
+		for _, c := range env.VideoEncoding {
+			fmt.Fprintf(w, "  %s: up to %s @ %.0ffps\n", c.Profile, c.MaxResolution, c.MaxFramerate)
+		}
+	}
+	fmt.Fprintln(w)
+}
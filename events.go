@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event types emitted while a run progresses.
+const (
+	EventRunStarted          = "run_started"
+	EventEnvironmentCaptured = "environment_captured"
+	EventTestStarted         = "test_started"
+	EventCPUSample           = "cpu_sample"
+	EventMetricRecorded      = "metric_recorded"
+	EventTestFinished        = "test_finished"
+	EventRunFinished         = "run_finished"
+)
+
+// Event is a structured progress event, written one per line by -events
+// ndjson and streamed by the serve API.
+type Event struct {
+	Time        time.Time
+	Type        string
+	Run         string           `json:",omitempty"`
+	Test        string           `json:",omitempty"`
+	Tests       []string         `json:",omitempty"`
+	Environment *EnvironmentInfo `json:",omitempty"`
+	Metric      string           `json:",omitempty"`
+	Value       interface{}      `json:",omitempty"`
+	Result      *HistoryTest     `json:",omitempty"`
+	Failed      *int             `json:",omitempty"`
+	Interrupted bool             `json:",omitempty"`
+	Message     string           `json:",omitempty"`
+}
+
+// emit sends event to the harness's event handler, if any.
+func (h *TestHarness) emit(event Event) {
+	if h.onEvent == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Run == "" {
+		event.Run = h.label
+	}
+	h.onEvent(event)
+}
+
+// emitMetrics emits a metric_recorded event for every metric of result, in
+// sorted order.
+func (h *TestHarness) emitMetrics(result *TestResult) {
+	if h.onEvent == nil {
+		return
+	}
+
+	// Sort keys alphabetically
+	keys := make([]string, 0, len(result.Metrics))
+	for key := range result.Metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h.emit(Event{Type: EventMetricRecorded, Test: result.TestName, Metric: key, Value: jsonSafe(result.Metrics[key])})
+	}
+}
+
+// streamCPUSamples emits each new sample recorded by monitor until the
+// returned function is called.
+func (h *TestHarness) streamCPUSamples(monitor *ChromeCPUMonitor, test string) (stop func()) {
+	if h.onEvent == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(monitor.interval)
+		defer ticker.Stop()
+
+		sent := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			samples := monitor.GetSamples()
+			for _, s := range samples[sent:] {
+				h.emit(Event{Time: s.Timestamp, Type: EventCPUSample, Test: test, Value: s.Usage})
+			}
+			sent = len(samples)
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// newEventWriter returns an event handler that writes events to w as
+// newline-delimited JSON. It is safe for concurrent use.
+func newEventWriter(w io.Writer) func(Event) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(event); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write event: %v\n", err)
+		}
+	}
+}
+
+// jsonSafe replaces values JSON can't represent, NaN and infinity, with nil.
+func jsonSafe(v interface{}) interface{} {
+	if f, ok := numericValue(v); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// HistoryRecord is one run as stored in ~/.chromebench/history/<ID>.json.
+type HistoryRecord struct {
+	ID          string
+	StartTime   time.Time
+	EndTime     time.Time
+	Label       string
+	ChromePath  string
+	RemoteURL   string
+	Interrupted bool
+	Config      HistoryConfig
+	Environment *EnvironmentInfo
+	Preflight   *PreflightReport
+	Tests       []HistoryTest
+}
+
+// HistoryConfig is how chromebench was configured for a run.
+type HistoryConfig struct {
+	Tests       []string
+	ChromeFlags []string
+	Headless    bool
+	Collectors  []string
+	Isolation   string
+	Timeout     time.Duration
+}
+
+// HistoryTest is a TestResult without the raw samples.
+type HistoryTest struct {
+	Name        string
+	StartTime   time.Time
+	EndTime     time.Time
+	Success     bool
+	Error       string
+	TimedOut    bool
+	Aborted     bool
+	Crashed     bool
+	CrashReason string
+	Throttled   bool
+	Metrics     map[string]interface{}
+	Violations  []string
+}
+
+// historyIndexEntry summarizes a run in index.json so listing the history
+// doesn't read every run.
+type historyIndexEntry struct {
+	ID        string
+	StartTime time.Time
+	Label     string
+	Browser   string
+	Tests     int
+	Failed    int
+}
+
+func historyDir() (string, error) {
+	return chromebenchDir("history")
+}
+
+// saveHistory stores run in the history and returns its ID.
+func saveHistory(run *RunResult, h *TestHarness) (string, error) {
+	dir, err := historyDir()
+	if err != nil {
+		return "", err
+	}
+
+	record := newHistoryRecord(run, h)
+	record.ID = run.StartTime.Format("20060102-150405")
+	if run.Label != "" {
+		record.ID += "-" + sanitizeID(run.Label)
+	}
+	base := record.ID
+	for i := 2; ; i++ {
+		// Create the file to claim the ID, so concurrent runs can't both
+		// pick it
+		f, err := os.OpenFile(filepath.Join(dir, record.ID+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		record.ID = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	if err := writeJSONFile(filepath.Join(dir, record.ID+".json"), record); err != nil {
+		return "", err
+	}
+
+	// A run saved at the same time can overwrite this update, in which case
+	// loadHistoryIndex adds the entry back from the run's file
+	index, err := readHistoryIndex(dir)
+	if err != nil {
+		return "", err
+	}
+	index = append(index, newHistoryIndexEntry(record))
+	if err := writeJSONFile(filepath.Join(dir, "index.json"), index); err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+func newHistoryIndexEntry(record *HistoryRecord) historyIndexEntry {
+	entry := historyIndexEntry{
+		ID:        record.ID,
+		StartTime: record.StartTime,
+		Label:     record.Label,
+		Tests:     len(record.Tests),
+	}
+	if record.Environment != nil {
+		entry.Browser = record.Environment.Browser
+	}
+	for _, t := range record.Tests {
+		if !t.Success {
+			entry.Failed++
+		}
+	}
+	return entry
+}
+
+func newHistoryRecord(run *RunResult, h *TestHarness) *HistoryRecord {
+	record := &HistoryRecord{
+		StartTime:   run.StartTime,
+		EndTime:     run.EndTime,
+		Label:       run.Label,
+		ChromePath:  run.ChromePath,
+		RemoteURL:   run.RemoteURL,
+		Interrupted: run.Interrupted,
+		Environment: run.Environment,
+		Preflight:   run.Preflight,
+		Config: HistoryConfig{
+			ChromeFlags: run.ChromeFlags,
+			Headless:    h.headless,
+			Collectors:  h.collectors,
+			Isolation:   run.Isolation,
+			Timeout:     h.timeout,
+		},
+	}
+	for _, test := range h.tests {
+		record.Config.Tests = append(record.Config.Tests, test.Name())
+	}
+
+	for _, result := range run.Results {
+		record.Tests = append(record.Tests, newHistoryTest(result, run.Violations))
+	}
+	return record
+}
+
+// newHistoryTest converts result for storage, with the violations among
+// violations that belong to it.
+func newHistoryTest(result TestResult, violations []ThresholdViolation) HistoryTest {
+	t := HistoryTest{
+		Name:        result.TestName,
+		StartTime:   result.StartTime,
+		EndTime:     result.EndTime,
+		Success:     result.Success,
+		TimedOut:    result.TimedOut,
+		Aborted:     result.Aborted,
+		Crashed:     result.Crashed,
+		CrashReason: result.CrashReason,
+		Throttled:   result.Throttled,
+		Metrics:     make(map[string]interface{}),
+	}
+	if result.Error != nil {
+		t.Error = result.Error.Error()
+	}
+	for key, value := range result.Metrics {
+		// JSON can't represent NaN or infinity
+		if v, ok := value.(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			continue
+		}
+		t.Metrics[key] = value
+	}
+	for _, v := range violations {
+		if v.TestName == result.TestName {
+			t.Violations = append(t.Violations, v.String())
+		}
+	}
+	return t
+}
+
+// RunResult converts the record back for printing with printSummary.
+func (r *HistoryRecord) RunResult() *RunResult {
+	run := &RunResult{
+		Label:       r.Label,
+		ChromePath:  r.ChromePath,
+		RemoteURL:   r.RemoteURL,
+		StartTime:   r.StartTime,
+		EndTime:     r.EndTime,
+		Environment: r.Environment,
+		Preflight:   r.Preflight,
+		Isolation:   r.Config.Isolation,
+		ChromeFlags: r.Config.ChromeFlags,
+		Interrupted: r.Interrupted,
+	}
+	for _, t := range r.Tests {
+		result := TestResult{
+			TestName:    t.Name,
+			StartTime:   t.StartTime,
+			EndTime:     t.EndTime,
+			Success:     t.Success,
+			Metrics:     t.Metrics,
+			Throttled:   t.Throttled,
+			Crashed:     t.Crashed,
+			CrashReason: t.CrashReason,
+			TimedOut:    t.TimedOut,
+			Aborted:     t.Aborted,
+		}
+		if t.Error != "" {
+			result.Error = errors.New(t.Error)
+		}
+		run.Results = append(run.Results, result)
+	}
+	return run
+}
+
+func readHistoryIndex(dir string) ([]historyIndexEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index []historyIndexEntry
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing history index: %w", err)
+	}
+	return index, nil
+}
+
+// loadHistoryIndex returns the index of every stored run, oldest first. The
+// index is only a cache of the run files: runs missing from it, because two
+// runs updated it at the same time, are read from their files and the index
+// is rewritten.
+func loadHistoryIndex(dir string) ([]historyIndexEntry, error) {
+	index, err := readHistoryIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]bool)
+	for _, file := range files {
+		if id := strings.TrimSuffix(filepath.Base(file), ".json"); id != "index" {
+			stored[id] = true
+		}
+	}
+
+	// Drop entries for runs that were deleted
+	var entries []historyIndexEntry
+	indexed := make(map[string]bool)
+	for _, e := range index {
+		if stored[e.ID] && !indexed[e.ID] {
+			indexed[e.ID] = true
+			entries = append(entries, e)
+		}
+	}
+	changed := len(entries) != len(index)
+
+	for id := range stored {
+		if indexed[id] {
+			continue
+		}
+		record, err := readHistoryRecord(dir, id)
+		if err != nil {
+			// Most likely a run that is still being saved
+			continue
+		}
+		record.ID = id
+		entries = append(entries, newHistoryIndexEntry(record))
+		changed = true
+	}
+
+	if changed {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].StartTime.Before(entries[j].StartTime)
+		})
+		// If this fails the next listing repairs the index again
+		writeJSONFile(filepath.Join(dir, "index.json"), entries)
+	}
+	return entries, nil
+}
+
+func readHistoryRecord(dir, id string) (*HistoryRecord, error) {
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var record HistoryRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+// writeJSONFile writes v to path via a temporary file so a crash never leaves
+// a truncated file behind.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	// A temporary file of its own, so concurrent writers of path don't
+	// clobber each other's half-written data
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func sanitizeID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage:")
+		fmt.Fprintln(fs.Output(), "  chromebench history list")
+		fmt.Fprintln(fs.Output(), "  chromebench history show [id]")
+		fmt.Fprintln(fs.Output(), "  chromebench history trend [-label label] <test> <metric>")
+	}
+	fs.Parse(args)
+
+	dir, err := historyDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	index, err := loadHistoryIndex(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch fs.Arg(0) {
+	case "list":
+		historyList(index)
+	case "show":
+		historyShow(dir, index, fs.Arg(1))
+	case "trend":
+		historyTrend(dir, index, fs.Args()[1:])
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func historyList(index []historyIndexEntry) {
+	if len(index) == 0 {
+		fmt.Println("No runs recorded yet")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDate\tBrowser\tLabel\tTests\tFailed\t")
+	for _, e := range index {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t\n", e.ID, e.StartTime.Format("2006-01-02 15:04"), e.Browser, e.Label, e.Tests, e.Failed)
+	}
+	w.Flush()
+}
+
+// historyShow prints a stored run like the summary at the end of a run. With
+// no ID the latest run is shown.
+func historyShow(dir string, index []historyIndexEntry, id string) {
+	if id == "" {
+		if len(index) == 0 {
+			log.Fatal("No runs recorded yet")
+		}
+		id = index[len(index)-1].ID
+	}
+	record, err := readHistoryRecord(dir, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Run %s\n", record.ID)
+	if len(record.Config.ChromeFlags) > 0 {
+		fmt.Printf("Chrome flags: %s\n", strings.Join(record.Config.ChromeFlags, " "))
+	}
+	fmt.Println()
+	if record.Environment != nil {
+		record.Environment.Print(os.Stdout)
+	}
+	run := record.RunResult()
+	printSummary(os.Stdout, run)
+	for _, t := range record.Tests {
+		for _, v := range t.Violations {
+			fmt.Printf("Threshold violation: %s\n", v)
+		}
+	}
+}
+
+// historyTrend prints a metric of one test across every stored run that
+// reported it, oldest first.
+func historyTrend(dir string, index []historyIndexEntry, args []string) {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	label := fs.String("label", "", "Only include runs with this -chrome label")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatal("Usage: chromebench history trend [-label label] <test> <metric>")
+	}
+	testName, metric := fs.Arg(0), fs.Arg(1)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Date\tBrowser\tLabel\t%s\tChange\t\n", metric)
+
+	found := false
+	var previous float64
+	for _, e := range index {
+		if *label != "" && e.Label != *label {
+			continue
+		}
+		record, err := readHistoryRecord(dir, e.ID)
+		if err != nil {
+			log.Printf("Skipping %s: %v", e.ID, err)
+			continue
+		}
+		for _, t := range record.Tests {
+			if t.Name != testName || !t.Success {
+				continue
+			}
+			value, ok := numericValue(t.Metrics[metric])
+			if !ok {
+				continue
+			}
+
+			change := ""
+			if found && previous != 0 {
+				change = fmt.Sprintf("%+.1f%%", (value-previous)/math.Abs(previous)*100)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\t\n", record.StartTime.Format("2006-01-02 15:04"), e.Browser, e.Label, value, change)
+			previous = value
+			found = true
+		}
+	}
+
+	if !found {
+		log.Fatalf("No successful runs of %s reported %s", testName, metric)
+	}
+	w.Flush()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSanitizeID(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"stable", "stable"},
+		{"Canary-120.0_x64", "Canary-120.0_x64"},
+		{"my build", "my_build"},
+		{"../../etc/passwd", ".._.._etc_passwd"},
+		{`C:\chrome.exe`, "C__chrome.exe"},
+		{"a=b,c", "a_b_c"},
+		{"naïve", "na_ve"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeID(tt.in); got != tt.want {
+			t.Errorf("sanitizeID(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSaveHistoryConcurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	const runs = 8
+	ids := make([]string, runs)
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run := &RunResult{Label: "stable", StartTime: start, Results: []TestResult{{TestName: "basic", Success: i%2 == 0}}}
+			id, err := saveHistory(run, &TestHarness{})
+			if err != nil {
+				t.Errorf("saveHistory: %v", err)
+			}
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("ID %s assigned twice", id)
+		}
+		seen[id] = true
+	}
+
+	dir, err := historyDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := loadHistoryIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != runs {
+		t.Fatalf("index has %d entries, want %d", len(index), runs)
+	}
+	failed := 0
+	for _, e := range index {
+		if !seen[e.ID] {
+			t.Errorf("unexpected index entry %s", e.ID)
+		}
+		failed += e.Failed
+	}
+	if failed != runs/2 {
+		t.Errorf("index counts %d failed tests, want %d", failed, runs/2)
+	}
+}
+
+func TestLoadHistoryIndexRepairs(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"b", "a", "c"} {
+		record := &HistoryRecord{ID: id, StartTime: start.Add(time.Duration(i) * time.Hour), Tests: []HistoryTest{{Name: "basic"}}}
+		if err := writeJSONFile(filepath.Join(dir, id+".json"), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The index lost "a" and "c" and still lists the deleted "gone"
+	stale := []historyIndexEntry{{ID: "gone", StartTime: start}, {ID: "b", StartTime: start}}
+	if err := writeJSONFile(filepath.Join(dir, "index.json"), stale); err != nil {
+		t.Fatal(err)
+	}
+	// A run whose ID is claimed but not yet written is skipped
+	if err := os.WriteFile(filepath.Join(dir, "d.json"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := loadHistoryIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range index {
+		got = append(got, e.ID)
+	}
+	if want := []string{"b", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("index = %v, want %v", got, want)
+	}
+	if index[1].Tests != 1 || index[1].Failed != 1 {
+		t.Errorf("entry for a = %+v, want 1 test, 1 failed", index[1])
+	}
+
+	saved, err := readHistoryIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 3 {
+		t.Errorf("repaired index not saved: %+v", saved)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// htmlReport is the data behind the -report template.
+type htmlReport struct {
+	Generated   string
+	Version     string
+	HasBaseline bool
+	Runs        []htmlRun
+	Comparison  []comparisonRow
+	Labels      []string
+}
+
+type htmlRun struct {
+	Title       string
+	Interrupted bool
+	Environment []htmlField
+	Violations  []string
+	Tests       []htmlTest
+}
+
+type htmlField struct {
+	Name  string
+	Value string
+}
+
+type htmlTest struct {
+	Name     string
+	Anchor   string
+	Status   string
+	Failed   bool
+	Duration string
+	Error    string
+	Notes    []string
+	Metrics  []htmlMetric
+	Charts   []template.HTML
+}
+
+type htmlMetric struct {
+	Name     string
+	Value    string
+	Baseline string
+	Change   string
+}
+
+// sampleCharts lists the collector samples drawn as time series, in addition
+// to CPU usage.
+var sampleCharts = []struct {
+	Collector string
+	Key       string
+	Title     string
+}{
+	{"memory", "rss_mb", "Memory (MB)"},
+	{"thermal", "max_temp_c", "Temperature (°C)"},
+	{"power", "watts", "Power (W)"},
+}
+
+// writeHTMLReport renders runs as a single self-contained HTML file. Charts
+// are inline SVG so the report works offline and without scripts.
+func writeHTMLReport(path string, runs []*RunResult, baseline Baseline) error {
+	report := htmlReport{
+		Generated:   time.Now().Format("2006-01-02 15:04:05"),
+		Version:     version,
+		HasBaseline: baseline != nil,
+	}
+	for i, run := range runs {
+		report.Runs = append(report.Runs, newHTMLRun(i, run, baseline))
+	}
+	if len(runs) > 1 {
+		report.Comparison = comparisonRows(runs)
+		for _, run := range runs {
+			report.Labels = append(report.Labels, run.Label)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := htmlReportTemplate.Execute(f, report); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func newHTMLRun(index int, run *RunResult, baseline Baseline) htmlRun {
+	r := htmlRun{
+		Title:       "Results",
+		Interrupted: run.Interrupted,
+	}
+	if run.Label != "" {
+		r.Title = "Results: " + run.Label
+	}
+
+	var fields []htmlField
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, htmlField{name, value})
+		}
+	}
+	add("Chrome binary", run.ChromePath)
+	add("Remote browser", run.RemoteURL)
+	if env := run.Environment; env != nil {
+		add("Browser", env.Browser)
+		add("Revision", env.Revision)
+		add("User agent", env.UserAgent)
+		add("Command line", strings.Join(env.CommandLine, " "))
+		add("OS", strings.TrimSpace(env.OS+" "+env.OSVersion))
+		add("Kernel", env.Kernel)
+		add("Architecture", env.Arch)
+		if env.CPUCores > 0 {
+			add("CPU", fmt.Sprintf("%s (%d cores)", env.CPUModel, env.CPUCores))
+		}
+		if env.MemoryBytes > 0 {
+			add("Memory", fmt.Sprintf("%.1f GB", float64(env.MemoryBytes)/(1<<30)))
+		}
+		add("Machine model", env.MachineModel)
+		for i, gpu := range env.GPUDevices {
+			add(fmt.Sprintf("GPU %d", i), gpu.String())
+		}
+		var decode []string
+		for _, c := range env.VideoDecoding {
+			decode = append(decode, c.Profile)
+		}
+		add("Hardware decode", strings.Join(decode, ", "))
+	}
+	add("Test isolation", run.Isolation)
+	add("Started", run.StartTime.Format("2006-01-02 15:04:05"))
+	add("Duration", run.EndTime.Sub(run.StartTime).Round(time.Second).String())
+	r.Environment = fields
+
+	for _, v := range run.Violations {
+		r.Violations = append(r.Violations, v.String())
+	}
+
+	for _, result := range run.Results {
+		t := newHTMLTest(result, baseline[result.TestName])
+		t.Anchor = fmt.Sprintf("run%d-%s", index, result.TestName)
+		r.Tests = append(r.Tests, t)
+	}
+	return r
+}
+
+func newHTMLTest(result TestResult, baseline map[string]float64) htmlTest {
+	t := htmlTest{
+		Name:     result.TestName,
+		Status:   "passed",
+		Duration: result.EndTime.Sub(result.StartTime).Round(time.Millisecond).String(),
+	}
+	switch {
+	case result.Aborted:
+		t.Status = "aborted"
+	case result.Crashed:
+		t.Status = "crashed"
+	case result.TimedOut:
+		t.Status = "timed out"
+	case !result.Success:
+		t.Status = "failed"
+	}
+	t.Failed = !result.Success
+	if result.Error != nil {
+		t.Error = result.Error.Error()
+	}
+	if result.Crashed {
+		t.Notes = append(t.Notes, "Crashed: "+result.CrashReason)
+		for _, dump := range result.Minidumps {
+			t.Notes = append(t.Notes, "Minidump: "+dump)
+		}
+	}
+	if result.Throttled {
+		t.Notes = append(t.Notes, "Thermal throttling occurred during this test")
+	}
+
+	// Sort keys alphabetically
+	keys := make([]string, 0, len(result.Metrics))
+	for key := range result.Metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var subscores []chartBar
+	for _, key := range keys {
+		m := htmlMetric{Name: key, Value: fmt.Sprint(result.Metrics[key])}
+		if value, ok := numericValue(result.Metrics[key]); ok {
+			m.Value = formatMetric(value)
+			if base, ok := baseline[key]; ok {
+				m.Baseline = formatMetric(base)
+				if base != 0 {
+					m.Change = fmt.Sprintf("%+.1f%%", (value-base)/math.Abs(base)*100)
+				}
+			}
+			if name, ok := strings.CutPrefix(key, "subscore_"); ok {
+				subscores = append(subscores, chartBar{Label: name, Value: value})
+			}
+		}
+		t.Metrics = append(t.Metrics, m)
+	}
+
+	if len(result.CPUSamples) > 1 {
+		var xs, ys []float64
+		for _, s := range result.CPUSamples {
+			xs = append(xs, s.Timestamp.Sub(result.StartTime).Seconds())
+			ys = append(ys, s.Usage)
+		}
+		t.Charts = append(t.Charts, lineChart("CPU usage (%)", xs, ys))
+	}
+	for _, c := range sampleCharts {
+		samples := result.Samples[c.Collector]
+		if len(samples) < 2 {
+			continue
+		}
+		var xs, ys []float64
+		for _, s := range samples {
+			if v, ok := s.Values[c.Key]; ok {
+				xs = append(xs, s.Timestamp.Sub(result.StartTime).Seconds())
+				ys = append(ys, v)
+			}
+		}
+		if len(xs) > 1 {
+			t.Charts = append(t.Charts, lineChart(c.Title, xs, ys))
+		}
+	}
+	if len(subscores) > 0 {
+		t.Charts = append(t.Charts, barChart("Subscores", subscores))
+	}
+	return t
+}
+
+func formatMetric(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+const (
+	chartWidth  = 640
+	chartHeight = 200
+	chartLeft   = 50
+	chartRight  = 15
+	chartTop    = 25
+	chartBottom = 25
+)
+
+// lineChart draws ys against xs (seconds since the test started) as an SVG
+// line chart with a y axis starting at zero.
+func lineChart(title string, xs, ys []float64) template.HTML {
+	xMax := xs[len(xs)-1]
+	if xMax <= 0 {
+		xMax = 1
+	}
+	yMax := 0.0
+	for _, y := range ys {
+		yMax = math.Max(yMax, y)
+	}
+	if yMax <= 0 {
+		yMax = 1
+	}
+	yMax *= 1.1
+
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+	x := func(v float64) float64 { return chartLeft + v/xMax*plotW }
+	y := func(v float64) float64 { return chartTop + plotH - v/yMax*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="15" class="title">%s</text>`, chartLeft, html.EscapeString(title))
+	for _, v := range []float64{0, yMax / 2, yMax} {
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, chartLeft, chartWidth-chartRight, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="axis" text-anchor="end">%.3g</text>`, chartLeft-5, y(v)+4, v)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" class="axis">0s</text>`, chartLeft, chartHeight-8)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" class="axis" text-anchor="end">%.0fs</text>`, chartWidth-chartRight, chartHeight-8, xMax)
+
+	var points []string
+	for i := range xs {
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(xs[i]), y(ys[i])))
+	}
+	fmt.Fprintf(&b, `<polyline points="%s" class="line"/>`, strings.Join(points, " "))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+type chartBar struct {
+	Label string
+	Value float64
+}
+
+// barChart draws bars as a horizontal SVG bar chart.
+func barChart(title string, bars []chartBar) template.HTML {
+	const labelWidth, rowHeight, valueWidth = 170, 22, 70
+	height := chartTop + len(bars)*rowHeight + 5
+
+	maxValue := 0.0
+	for _, bar := range bars {
+		maxValue = math.Max(maxValue, bar.Value)
+	}
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+	barSpace := float64(chartWidth - labelWidth - valueWidth)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, height)
+	fmt.Fprintf(&b, `<text x="0" y="15" class="title">%s</text>`, html.EscapeString(title))
+	for i, bar := range bars {
+		top := chartTop + i*rowHeight
+		w := math.Max(bar.Value, 0) / maxValue * barSpace
+		fmt.Fprintf(&b, `<text x="%d" y="%d" class="axis" text-anchor="end">%s</text>`, labelWidth-8, top+14, html.EscapeString(bar.Label))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" class="bar"/>`, labelWidth, top+2, w, rowHeight-6)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="axis">%s</text>`, float64(labelWidth)+w+5, top+14, formatMetric(bar.Value))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc": func(n int) int { return n + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>chromebench report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 2em auto; max-width: 960px; color: #222; padding: 0 1em; }
+h1 { font-size: 1.6em; }
+h2 { border-bottom: 2px solid #ddd; padding-bottom: .2em; margin-top: 2em; }
+h3 { margin-top: 1.5em; }
+table { border-collapse: collapse; margin: .5em 0 1em; }
+th, td { text-align: left; padding: .25em .8em; border-bottom: 1px solid #eee; vertical-align: top; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+th { background: #f5f5f5; }
+.env td:first-child { color: #666; white-space: nowrap; }
+.status { font-weight: bold; color: #188038; }
+.status.failed { color: #c5221f; }
+.error, .violations li { color: #c5221f; }
+.note { color: #b06000; }
+.chart { width: 100%; max-width: 640px; display: block; margin: .5em 0; }
+.chart .title { font-size: 13px; font-weight: bold; fill: #333; }
+.chart .axis { font-size: 11px; fill: #666; }
+.chart .grid { stroke: #e5e5e5; }
+.chart .line { fill: none; stroke: #1a73e8; stroke-width: 1.5; }
+.chart .bar { fill: #1a73e8; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>chromebench report</h1>
+<p class="meta">Generated {{.Generated}} by chromebench {{.Version}}</p>
+{{range .Runs}}
+<h2>{{.Title}}</h2>
+{{if .Interrupted}}<p class="note">The run was interrupted; only the tests that finished are shown.</p>{{end}}
+<table class="env">
+{{range .Environment}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Violations}}
+<h3>Threshold violations</h3>
+<ul class="violations">
+{{range .Violations}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}
+<table>
+<tr><th>Test</th><th>Status</th><th>Duration</th></tr>
+{{range .Tests}}<tr><td><a href="#{{.Anchor}}">{{.Name}}</a></td><td class="status{{if .Failed}} failed{{end}}">{{.Status}}</td><td class="num">{{.Duration}}</td></tr>
+{{end}}</table>
+{{range .Tests}}
+<h3 id="{{.Anchor}}">{{.Name}} <span class="status{{if .Failed}} failed{{end}}">{{.Status}}</span></h3>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{range .Notes}}<p class="note">{{.}}</p>{{end}}
+{{if .Metrics}}
+<table>
+<tr><th>Metric</th><th>Value</th>{{if $.HasBaseline}}<th>Baseline</th><th>Change</th>{{end}}</tr>
+{{range .Metrics}}<tr><td>{{.Name}}</td><td class="num">{{.Value}}</td>{{if $.HasBaseline}}<td class="num">{{.Baseline}}</td><td class="num">{{.Change}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+{{range .Charts}}{{.}}
+{{end}}
+{{end}}
+{{end}}
+{{if .Comparison}}
+<h2>Comparison</h2>
+<table>
+<tr><th>Test / Metric</th>{{range .Labels}}<th>{{.}}</th>{{end}}</tr>
+{{range .Comparison}}{{if .Metric}}<tr><td>&nbsp;&nbsp;{{.Metric}}</td>{{range .Values}}<td class="num">{{.}}</td>{{end}}</tr>
+{{else}}<tr><th colspan="{{len $.Labels | inc}}">{{.Test}}</th></tr>
+{{end}}{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// jetStreamBundleURL is the pinned JetStream 2 release.
+const jetStreamBundleURL = "https://github.com/WebKit/JetStream/archive/refs/tags/v2.2.zip"
+
+type JetStreamTest struct {
+	bundle *BenchmarkBundle
+}
+
+func (t *JetStreamTest) Name() string {
+	return "jetstream"
+}
+
+// Timeout allows for the full suite on a slow machine.
+func (t *JetStreamTest) Timeout() time.Duration {
+	return 20 * time.Minute
+}
+
+func (t *JetStreamTest) Bundle() *BenchmarkBundle {
+	return t.bundle
+}
+
+// jetStreamSubtest is one JetStream benchmark's score.
+type jetStreamSubtest struct {
+	Name  string
+	Score *float64
+}
+
+// jetStreamResults is what JetStream reports at the end of a run.
+type jetStreamResults struct {
+	// Score is the overall score as JetStream displays it
+	Score    string
+	Subtests []jetStreamSubtest
+}
+
+// jetStreamScoresJS returns the overall score and each benchmark's score once
+// the run has finished, or null while it is still running. Scores JSON can't
+// represent, such as NaN, come back as null.
+const jetStreamScoresJS = `(() => {
+	const score = document.querySelector("#result-summary .score");
+	if (!score) return null;
+	return {
+		Score: score.textContent.trim(),
+		Subtests: JetStream.benchmarks.map(b => ({Name: b.name, Score: b.score}))
+	};
+})()`
+
+func (t *JetStreamTest) Run(ctx context.Context) (*TestResult, error) {
+	result := &TestResult{
+		TestName:  t.Name(),
+		StartTime: time.Now(),
+		Metrics:   make(map[string]interface{}),
+	}
+
+	baseURL, stop, err := t.bundle.Serve()
+	if err != nil {
+		result.EndTime = time.Now()
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+	defer stop()
+
+	var results *jetStreamResults
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(baseURL+"/index.html"),
+
+		// The start button appears once every benchmark has loaded
+		chromedp.WaitVisible(`#status a.button`),
+		chromedp.Evaluate(`JetStream.start(), undefined`, nil),
+
+		// Wait for every benchmark to finish
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			ticker := time.NewTicker(2 * time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-ticker.C:
+					if err := chromedp.Evaluate(jetStreamScoresJS, &results).Do(ctx); err != nil {
+						return err
+					}
+					if results != nil {
+						return nil
+					}
+				}
+			}
+		}),
+	)
+
+	result.EndTime = time.Now()
+
+	if err != nil {
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+
+	var overall float64
+	if _, err := fmt.Sscanf(results.Score, "%f", &overall); err != nil || overall <= 0 {
+		result.Success = false
+		result.Error = fmt.Errorf("jetstream reported an invalid score %q", results.Score)
+		return result, result.Error
+	}
+	result.Metrics["overall_score"] = overall
+
+	// Like JetStream's own score, the JS and Wasm scores are geometric means
+	// of their benchmarks' scores
+	var js, wasm []float64
+	var invalid []string
+	for _, s := range results.Subtests {
+		if s.Score == nil || math.IsNaN(*s.Score) || math.IsInf(*s.Score, 0) || *s.Score <= 0 {
+			invalid = append(invalid, s.Name)
+			continue
+		}
+		result.Metrics[fmt.Sprintf("subscore_%s", s.Name)] = *s.Score
+		if strings.HasSuffix(s.Name, "-wasm") {
+			wasm = append(wasm, *s.Score)
+		} else {
+			js = append(js, *s.Score)
+		}
+	}
+
+	if len(invalid) > 0 {
+		result.Success = false
+		result.Error = fmt.Errorf("jetstream reported no valid score for %s", strings.Join(invalid, ", "))
+		return result, result.Error
+	}
+
+	if len(js) > 0 {
+		result.Metrics["js_score"] = geometricMean(js)
+	}
+	if len(wasm) > 0 {
+		result.Metrics["wasm_score"] = geometricMean(wasm)
+	}
+
+	result.Success = true
+	return result, nil
+}
+
+func geometricMean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += math.Log(v)
+	}
+	return math.Exp(sum / float64(len(values)))
+}
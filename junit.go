@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr"`
+	Hostname   string           `xml:"hostname,attr,omitempty"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	TestCases  []junitTestCase  `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string           `xml:"name,attr"`
+	ClassName  string           `xml:"classname,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Failures   []junitFailure   `xml:"failure,omitempty"`
+	Skipped    *junitSkipped    `xml:"skipped,omitempty"`
+	SystemOut  string           `xml:"system-out,omitempty"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes runs as a JUnit XML report, one <testsuite> per run and
+// one <testcase> per test. Test errors and threshold violations are reported
+// as failures and metrics as testcase properties.
+func writeJUnit(path string, runs []*RunResult) error {
+	report := junitTestSuites{Name: "chromebench"}
+	var total float64
+	for _, run := range runs {
+		suite := newJUnitSuite(run)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		total += run.EndTime.Sub(run.StartTime).Seconds()
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = formatSeconds(total)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func newJUnitSuite(run *RunResult) junitTestSuite {
+	name := "chromebench"
+	if run.Label != "" {
+		name += "." + run.Label
+	}
+	hostname, _ := os.Hostname()
+
+	suite := junitTestSuite{
+		Name:      name,
+		Time:      formatSeconds(run.EndTime.Sub(run.StartTime).Seconds()),
+		Timestamp: run.StartTime.Format("2006-01-02T15:04:05"),
+		Hostname:  hostname,
+	}
+	var props []junitProperty
+	if env := run.Environment; env != nil {
+		props = appendProperty(props, "browser", env.Browser)
+		props = appendProperty(props, "os", strings.TrimSpace(env.OS+" "+env.OSVersion))
+		props = appendProperty(props, "cpu", env.CPUModel)
+		for i, gpu := range env.GPUDevices {
+			props = appendProperty(props, fmt.Sprintf("gpu.%d", i), gpu.String())
+		}
+	}
+	props = appendProperty(props, "chrome_path", run.ChromePath)
+	props = appendProperty(props, "remote_url", run.RemoteURL)
+	props = appendProperty(props, "isolation", run.Isolation)
+	suite.Properties = newJUnitProperties(props)
+
+	for _, result := range run.Results {
+		tc := junitTestCase{
+			Name:      result.TestName,
+			ClassName: name,
+			Time:      formatSeconds(result.EndTime.Sub(result.StartTime).Seconds()),
+		}
+
+		// Sort keys alphabetically
+		keys := make([]string, 0, len(result.Metrics))
+		for key := range result.Metrics {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var metrics []junitProperty
+		for _, key := range keys {
+			metrics = append(metrics, junitProperty{Name: key, Value: fmt.Sprint(result.Metrics[key])})
+		}
+		tc.Properties = newJUnitProperties(metrics)
+
+		switch {
+		case result.Aborted:
+			tc.Skipped = &junitSkipped{Message: "run interrupted"}
+			suite.Skipped++
+		case !result.Success:
+			tc.Failures = append(tc.Failures, junitResultFailure(result))
+		}
+		for _, v := range run.Violations {
+			if v.TestName == result.TestName {
+				tc.Failures = append(tc.Failures, junitFailure{
+					Message: v.String(),
+					Type:    "threshold",
+					Text:    v.Threshold.String(),
+				})
+			}
+		}
+		if result.Throttled {
+			tc.SystemOut = "thermal throttling occurred during this test"
+		}
+
+		suite.Tests++
+		if len(tc.Failures) > 0 {
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	return suite
+}
+
+func junitResultFailure(result TestResult) junitFailure {
+	f := junitFailure{Type: "error", Message: "test failed"}
+	if result.Error != nil {
+		f.Message = result.Error.Error()
+	}
+	switch {
+	case result.Crashed:
+		f.Type = "crash"
+		f.Text = result.CrashReason
+		for _, dump := range result.Minidumps {
+			f.Text += "\nminidump: " + dump
+		}
+	case result.TimedOut:
+		f.Type = "timeout"
+	}
+	return f
+}
+
+func newJUnitProperties(props []junitProperty) *junitProperties {
+	if len(props) == 0 {
+		return nil
+	}
+	return &junitProperties{Properties: props}
+}
+
+func appendProperty(props []junitProperty, name, value string) []junitProperty {
+	if value == "" {
+		return props
+	}
+	return append(props, junitProperty{Name: name, Value: value})
+}
+
+func formatSeconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	run := &RunResult{
+		Label:     "canary",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		Results: []TestResult{
+			{TestName: "passed", StartTime: start, EndTime: start.Add(1500 * time.Millisecond), Success: true,
+				Metrics: map[string]interface{}{"load_ms": 12.5, "fps": 60}},
+			{TestName: "failed", Error: errors.New("page <did> not load")},
+			{TestName: "crashed", Error: errors.New("renderer crashed"), Crashed: true, CrashReason: "gpu process exited",
+				Minidumps: []string{"/tmp/a.dmp"}},
+			{TestName: "timedout", Error: errors.New("deadline exceeded"), TimedOut: true},
+			{TestName: "aborted", Aborted: true},
+			{TestName: "slow", Success: true, Metrics: map[string]interface{}{"load_ms": 900.0}},
+			{TestName: "throttled", Success: true, Throttled: true},
+		},
+		Violations: []ThresholdViolation{{
+			TestName:  "slow",
+			Threshold: Threshold{Metric: "load_ms", Op: "<=", Value: 500},
+			Value:     900,
+			Limit:     500,
+		}},
+	}
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	if err := writeJUnit(path, []*RunResult{run}); err != nil {
+		t.Fatalf("writeJUnit: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("report doesn't start with the XML header")
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report isn't valid XML: %v\n%s", err, data)
+	}
+	if report.Tests != 7 || report.Failures != 4 || report.Time != "90.000" {
+		t.Errorf("testsuites tests=%d failures=%d time=%s, want 7, 4, 90.000", report.Tests, report.Failures, report.Time)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("got %d testsuites, want 1", len(report.Suites))
+	}
+	suite := report.Suites[0]
+	if suite.Name != "chromebench.canary" || suite.Skipped != 1 || suite.Timestamp != "2024-05-01T12:00:00" {
+		t.Errorf("testsuite name=%s skipped=%d timestamp=%s", suite.Name, suite.Skipped, suite.Timestamp)
+	}
+
+	cases := make(map[string]junitTestCase)
+	for _, tc := range suite.TestCases {
+		cases[tc.Name] = tc
+	}
+	tests := []struct {
+		name        string
+		failureType string
+		message     string
+		text        string
+		skipped     bool
+		systemOut   string
+	}{
+		{name: "passed"},
+		{name: "failed", failureType: "error", message: "page <did> not load"},
+		{name: "crashed", failureType: "crash", message: "renderer crashed", text: "gpu process exited\nminidump: /tmp/a.dmp"},
+		{name: "timedout", failureType: "timeout", message: "deadline exceeded"},
+		{name: "aborted", skipped: true},
+		{name: "slow", failureType: "threshold", message: "slow: load_ms = 900, want <= 500", text: "load_ms <= 500"},
+		{name: "throttled", systemOut: "thermal throttling occurred during this test"},
+	}
+	for _, tt := range tests {
+		tc, ok := cases[tt.name]
+		if !ok {
+			t.Errorf("%s: no testcase", tt.name)
+			continue
+		}
+		if tc.ClassName != "chromebench.canary" {
+			t.Errorf("%s: classname = %s", tt.name, tc.ClassName)
+		}
+		if (tc.Skipped != nil) != tt.skipped {
+			t.Errorf("%s: skipped = %v, want %v", tt.name, tc.Skipped != nil, tt.skipped)
+		}
+		if tc.SystemOut != tt.systemOut {
+			t.Errorf("%s: system-out = %q, want %q", tt.name, tc.SystemOut, tt.systemOut)
+		}
+		if tt.failureType == "" {
+			if len(tc.Failures) != 0 {
+				t.Errorf("%s: unexpected failures %+v", tt.name, tc.Failures)
+			}
+			continue
+		}
+		if len(tc.Failures) != 1 {
+			t.Errorf("%s: got %d failures, want 1", tt.name, len(tc.Failures))
+			continue
+		}
+		f := tc.Failures[0]
+		if f.Type != tt.failureType || f.Message != tt.message || f.Text != tt.text {
+			t.Errorf("%s: failure = %+v, want type=%q message=%q text=%q", tt.name, f, tt.failureType, tt.message, tt.text)
+		}
+	}
+
+	passed := cases["passed"]
+	if passed.Time != "1.500" {
+		t.Errorf("passed: time = %s, want 1.500", passed.Time)
+	}
+	if passed.Properties == nil || len(passed.Properties.Properties) != 2 ||
+		passed.Properties.Properties[0] != (junitProperty{"fps", "60"}) ||
+		passed.Properties.Properties[1] != (junitProperty{"load_ms", "12.5"}) {
+		t.Errorf("passed: properties = %+v, want fps and load_ms in order", passed.Properties)
+	}
+}
@@ -2,18 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
-
-	"github.com/chromedp/cdproto/browser"
-	"github.com/chromedp/cdproto/cdp"
-	"github.com/chromedp/cdproto/systeminfo"
-	"github.com/chromedp/chromedp"
 )
 
 var (
@@ -30,6 +28,47 @@ type TestResult struct {
 	Error      error
 	Metrics    map[string]interface{}
 	CPUSamples []CPUSample
+	Throttled  bool
+
+	// Crashed is set when the renderer, GPU or browser process died while
+	// the test was running. Minidumps lists any crash dumps that were saved.
+	Crashed     bool
+	CrashReason string
+	Minidumps   []string
+
+	// TimedOut is set when the test was cancelled by its timeout, Aborted
+	// when it was cancelled because the run was interrupted.
+	TimedOut bool
+	Aborted  bool
+
+	// Samples holds the raw samples recorded by each collector, keyed by
+	// collector name.
+	Samples map[string][]Sample
+}
+
+// RunResult holds everything recorded during one invocation of RunTests.
+type RunResult struct {
+	// Label and ChromePath identify the browser build when -chrome is used,
+	// RemoteURL the debugging endpoint when -remote is used.
+	Label      string
+	ChromePath string
+	RemoteURL  string
+
+	StartTime   time.Time
+	EndTime     time.Time
+	Environment *EnvironmentInfo
+	Preflight   *PreflightReport
+	Isolation   string
+	Results     []TestResult
+
+	// ChromeFlags are the extra flags Chrome was launched with.
+	ChromeFlags []string
+
+	// Interrupted is set when the run was stopped early by SIGINT/SIGTERM.
+	Interrupted bool
+
+	// Violations lists the -threshold budgets the results didn't meet.
+	Violations []ThresholdViolation
 }
 
 type CPUSample struct {
@@ -42,56 +81,229 @@ type Test interface {
 	Run(ctx context.Context) (*TestResult, error)
 }
 
+// TimeoutTest is implemented by tests whose expected duration differs from
+// the -timeout default.
+type TimeoutTest interface {
+	Timeout() time.Duration
+}
+
 type TestHarness struct {
 	tests       []Test
+	label       string
+	execPath    string
+	remoteURL   string
 	chromeFlags []string
 	headless    bool
+	preflight   PreflightConfig
+
+	// cooldownTemp, if non-zero, is the temperature in °C the host must
+	// drop below before each test after the first.
+	cooldownTemp    float64
+	cooldownTimeout time.Duration
+
+	collectors []string
+	isolation  string
+
+	// timeout is the timeout of tests without their own; testTimeouts
+	// overrides it and the tests' own for individual tests by name.
+	timeout      time.Duration
+	testTimeouts map[string]time.Duration
+
+	// sinks receives each result as soon as its test finishes.
+	sinks *sinkDispatcher
+
+	// onEvent, if set, receives progress events as the run goes.
+	onEvent func(Event)
+
+	// out receives the human-readable progress of the run.
+	out io.Writer
+}
+
+func printBanner(w io.Writer) {
+	fmt.Fprintf(w, "\nchromebench %s (%s/%s)\n", version, commit, buildDate)
 }
 
 func main() {
-	fmt.Printf("\nchromebench %s (%s/%s)\n", version, commit, buildDate)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bisect":
+			printBanner(os.Stdout)
+			runBisect(os.Args[2:])
+			return
+		case "history":
+			printBanner(os.Stdout)
+			runHistory(os.Args[2:])
+			return
+		case "serve":
+			printBanner(os.Stdout)
+			runServe(os.Args[2:])
+			return
+		}
+	}
+
+	var chromeBinaries chromeBinariesFlag
+	flag.Var(&chromeBinaries, "chrome", "Chrome binary to run as [label=]path; repeat to compare several builds")
+	var thresholds thresholdsFlag
+	flag.Var(&thresholds, "threshold", "Performance budget as \"[test] metric op value\", e.g. \"motionmark overall_score >= 900\"; repeatable")
 	var (
 		includeTests   = flag.String("include", "", "Comma-separated list of tests to include")
 		excludeTests   = flag.String("exclude", "", "Comma-separated list of tests to exclude")
 		headless       = flag.Bool("headless", false, "Run Chrome in headless mode")
 		listTests      = flag.Bool("list", false, "List available tests")
 		downloadVideos = flag.Bool("download-videos", false, "Download test videos and exit")
+		offline        = flag.Bool("offline", false, "Refuse all network fetches and fail if any test asset is missing")
+		mirror         = flag.String("mirror", "", "Base URL of a mirror to fetch remote test assets from")
+		requireIdle    = flag.String("require-idle", IdlePolicyWarn, "Pre-flight idle policy: off, warn, wait or abort")
+		idleCPU        = flag.Float64("idle-cpu", 10, "Maximum system CPU load percent for the host to count as idle")
+		idleTimeout    = flag.Duration("idle-timeout", 2*time.Minute, "How long -require-idle=wait waits for the host to become idle")
+		cooldownTemp   = flag.Float64("cooldown-temp", 0, "Wait between tests until all thermal zones are below this temperature in °C (0 disables)")
+		cooldownWait   = flag.Duration("cooldown-timeout", 5*time.Minute, "Maximum time to wait for -cooldown-temp between tests")
+		collectorList  = flag.String("collectors", strings.Join(defaultCollectors, ","), "Comma-separated list of metric collectors to run during each test")
+		isolate        = flag.String("isolate", IsolateNone, "Test isolation: none (shared tab), tab (new tab per test) or browser (new browser and profile per test)")
+		remote         = flag.String("remote", "", "Attach to a running browser at this remote debugging URL (ws://host:9222/...) instead of launching Chrome")
+		timeout        = flag.Duration("timeout", defaultTestTimeout, "Default timeout for tests without their own")
+		testTimeouts   = flag.String("test-timeout", "", "Comma-separated per-test timeout overrides, e.g. motionmark=10m,video-1080p60-h264=2m")
+		thresholdFile  = flag.String("thresholds", "", "File with one -threshold per line")
+		baselineFile   = flag.String("baseline", "", "Baseline results for relative thresholds such as \"overall_score >= baseline-5%\"")
+		saveBaseline   = flag.String("save-baseline", "", "Write this run's metrics to a baseline file")
+		junitReport    = flag.String("junit", "", "Write a JUnit XML report to this file")
+		htmlReport     = flag.String("report", "", "Write a self-contained HTML report to this file")
+		format         = flag.String("format", FormatText, "Results format: text, csv or markdown")
+		output         = flag.String("output", "", "Write csv or markdown results to this file instead of stdout")
+		openMetrics    = flag.String("openmetrics", "", "Write results in OpenMetrics text format to this file (for node_exporter's textfile collector)")
+		pushgateway    = flag.String("pushgateway", "", "Push results to the Pushgateway at this URL")
+		pushJob        = flag.String("push-job", "chromebench", "Job name to push results under with -pushgateway")
+		influxURL      = flag.String("influx", "", "POST each test result in InfluxDB line protocol to this write URL")
+		influxToken    = flag.String("influx-token", "", "InfluxDB API token for -influx")
+		webhookURL     = flag.String("webhook", "", "POST each test result as JSON to this URL")
+		noHistory      = flag.Bool("no-history", false, "Don't record this run in ~/.chromebench/history")
+		events         = flag.String("events", "", "Stream progress events in this format: ndjson")
+		eventsOutput   = flag.String("events-output", "", "Write -events to this file instead of stdout")
 	)
 	flag.Parse()
 
+	if !validIdlePolicy(*requireIdle) {
+		log.Fatalf("Invalid -require-idle policy %q", *requireIdle)
+	}
+
+	collectors, err := parseCollectors(*collectorList)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !validFormat(*format) {
+		log.Fatalf("Invalid -format %q", *format)
+	}
+	if *output != "" && *format == FormatText {
+		log.Fatal("-output requires -format csv or markdown")
+	}
+
+	if *events != "" && *events != "ndjson" {
+		log.Fatalf("Invalid -events format %q", *events)
+	}
+	if *eventsOutput != "" && *events == "" {
+		log.Fatal("-events-output requires -events ndjson")
+	}
+
+	// A table or event stream on stdout gets stdout to itself, so it can be
+	// piped; everything else printed goes to stderr instead
+	tableToStdout := *format != FormatText && *output == ""
+	eventsToStdout := *events != "" && *eventsOutput == ""
+	if tableToStdout && eventsToStdout {
+		log.Fatal("-events and -format can't both write to stdout; use -events-output or -output")
+	}
+	out := io.Writer(os.Stdout)
+	if tableToStdout || eventsToStdout {
+		out = os.Stderr
+	}
+	printBanner(out)
+
+	if !validIsolation(*isolate) {
+		log.Fatalf("Invalid -isolate mode %q", *isolate)
+	}
+
+	if *remote != "" {
+		if len(chromeBinaries) > 0 {
+			log.Fatal("-remote cannot be combined with -chrome")
+		}
+		if *isolate == IsolateBrowser {
+			log.Fatal("-isolate=browser is not supported with -remote")
+		}
+		if *headless || len(flag.Args()) > 0 {
+			fmt.Fprintln(out, "Note: -headless and Chrome flags are ignored with -remote")
+		}
+	}
+
+	timeoutOverrides, err := parseTestTimeouts(*testTimeouts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *thresholdFile != "" {
+		fileThresholds, err := readThresholds(*thresholdFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		thresholds = append(thresholds, fileThresholds...)
+	}
+
+	var baseline Baseline
+	if *baselineFile != "" {
+		if baseline, err = loadBaseline(*baselineFile); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		for _, t := range thresholds {
+			if t.Relative {
+				log.Fatalf("Threshold %q needs -baseline", t)
+			}
+		}
+	}
+
 	harness := &TestHarness{
-		headless: *headless,
+		headless:  *headless,
+		remoteURL: *remote,
+		preflight: PreflightConfig{
+			Policy:        *requireIdle,
+			MaxCPUPercent: *idleCPU,
+			Timeout:       *idleTimeout,
+		},
+		cooldownTemp:    *cooldownTemp,
+		cooldownTimeout: *cooldownWait,
+		collectors:      collectors,
+		isolation:       *isolate,
+		timeout:         *timeout,
+		testTimeouts:    timeoutOverrides,
+		out:             out,
 	}
 
 	// Parse Chrome flags after "--"
 	harness.chromeFlags = flag.Args()
 
+	assets := &AssetConfig{
+		Offline: *offline,
+		Mirror:  *mirror,
+		out:     out,
+	}
+
 	// Initialize video cache
-	videoCache, err := NewVideoCache()
+	videoCache, err := NewVideoCache(assets)
 	if err != nil {
 		log.Fatalf("Failed to initialize video cache: %v", err)
 	}
 
 	// Register all available tests
-	allTests := []Test{
-		&MotionMarkTest{},
-	}
-
-	// Add video tests with local paths
-	for _, videoInfo := range testVideos {
-		localPath := videoCache.GetVideoPath(videoInfo)
-		allTests = append(allTests, &VideoTest{
-			name:       videoInfo.Name,
-			videoURL:   "file://" + localPath,
-			resolution: videoInfo.Resolution,
-		})
-	}
+	allTests := registerTests(assets, videoCache)
 
 	if *listTests {
 		fmt.Println("Available tests:")
 		for _, test := range allTests {
 			fmt.Printf("  - %s\n", test.Name())
 		}
+		fmt.Println("\nAvailable collectors:")
+		for _, name := range availableCollectors() {
+			fmt.Printf("  - %s\n", name)
+		}
 		return
 	}
 
@@ -109,9 +321,224 @@ func main() {
 		log.Fatal("No tests to run")
 	}
 
+	ctx, cancel := interruptContext(out)
+	defer cancel()
+
+	// Make sure every asset the selected tests need is available
+	assets.ctx = ctx
+	if err := prepareAssets(harness.tests, assets, videoCache); err != nil {
+		if ctx.Err() != nil {
+			fmt.Fprintln(out, "\nInterrupted before any test ran")
+			os.Exit(exitCode(nil, true))
+		}
+		log.Fatal(err)
+	}
+
+	var sinks []*resultSink
+	if *influxURL != "" {
+		sinks = append(sinks, newInfluxSink(*influxURL, *influxToken))
+	}
+	if *webhookURL != "" {
+		sinks = append(sinks, newWebhookSink(*webhookURL))
+	}
+	if len(sinks) > 0 {
+		if harness.sinks, err = newSinkDispatcher(sinks); err != nil {
+			log.Fatalf("Failed to set up result sinks: %v", err)
+		}
+	}
+
+	var eventsFile *os.File
+	if *events != "" {
+		w := io.Writer(os.Stdout)
+		if *eventsOutput != "" {
+			if eventsFile, err = os.Create(*eventsOutput); err != nil {
+				log.Fatalf("Failed to create events output: %v", err)
+			}
+			w = eventsFile
+		}
+		harness.onEvent = newEventWriter(w)
+	}
+
+	// Run tests once per browser build, or once with the default Chrome
+	binaries := []ChromeBinary(chromeBinaries)
+	if len(binaries) == 0 {
+		binaries = []ChromeBinary{{}}
+	}
+
+	var runs []*RunResult
+	interrupted := false
+	for _, binary := range binaries {
+		if binary.Path != "" {
+			fmt.Fprintf(out, "=== Chrome: %s (%s) ===\n\n", binary.Label, binary.Path)
+		}
+		harness.label = binary.Label
+		harness.execPath = binary.Path
+
+		run, err := harness.RunTests(ctx)
+		if err != nil {
+			// Interrupted before the first test, e.g. during the pre-flight
+			// check
+			if ctx.Err() != nil {
+				interrupted = true
+				break
+			}
+			log.Printf("Run failed: %v", err)
+			continue
+		}
+		runs = append(runs, run)
+
+		if run.Interrupted {
+			interrupted = true
+			break
+		}
+	}
+
+	// Wait for results still being delivered
+	harness.sinks.Close()
+	if eventsFile != nil {
+		if err := eventsFile.Close(); err != nil {
+			log.Printf("Failed to write events output: %v", err)
+		}
+	}
+
+	if len(runs) == 0 {
+		if interrupted {
+			fmt.Fprintln(out, "Interrupted before any test ran")
+			os.Exit(exitCode(runs, true))
+		}
+		log.Fatal("No tests were run")
+	}
+
+	// Check performance budgets
+	for _, run := range runs {
+		run.Violations = evaluateThresholds(out, run, thresholds, baseline)
+	}
+
+	// Print summary, unless a table is going to stdout instead
+	if *format == FormatText || *output != "" {
+		for _, run := range runs {
+			printSummary(out, run)
+		}
+		if len(runs) > 1 {
+			printComparison(out, runs)
+		}
+	}
+	if *format != FormatText {
+		if err := writeTable(*format, *output, runs); err != nil {
+			log.Printf("Failed to write %s results: %v", *format, err)
+		} else if *output != "" {
+			fmt.Fprintf(out, "Results written to %s\n", *output)
+		}
+	}
+	for _, run := range runs {
+		printViolations(out, run)
+	}
+
+	if !*noHistory {
+		for _, run := range runs {
+			if id, err := saveHistory(run, harness); err != nil {
+				log.Printf("Failed to save run to history: %v", err)
+			} else {
+				fmt.Fprintf(out, "Run saved to history as %s\n", id)
+			}
+		}
+	}
+
+	if *openMetrics != "" {
+		if err := writeOpenMetricsFile(*openMetrics, runs); err != nil {
+			log.Printf("Failed to write OpenMetrics file: %v", err)
+		} else {
+			fmt.Fprintf(out, "OpenMetrics written to %s\n", *openMetrics)
+		}
+	}
+
+	if *pushgateway != "" {
+		if err := pushMetrics(*pushgateway, *pushJob, runs); err != nil {
+			log.Printf("Failed to push metrics: %v", err)
+		} else {
+			fmt.Fprintf(out, "Metrics pushed to %s\n", *pushgateway)
+		}
+	}
+
+	if *junitReport != "" {
+		if err := writeJUnit(*junitReport, runs); err != nil {
+			log.Printf("Failed to write JUnit report: %v", err)
+		} else {
+			fmt.Fprintf(out, "JUnit report written to %s\n", *junitReport)
+		}
+	}
+
+	if *htmlReport != "" {
+		if err := writeHTMLReport(*htmlReport, runs, baseline); err != nil {
+			log.Printf("Failed to write HTML report: %v", err)
+		} else {
+			fmt.Fprintf(out, "HTML report written to %s\n", *htmlReport)
+		}
+	}
+
+	if *saveBaseline != "" {
+		if err := newBaseline(runs[0]).Save(*saveBaseline); err != nil {
+			log.Printf("Failed to save baseline: %v", err)
+		} else {
+			fmt.Fprintf(out, "Baseline saved to %s\n", *saveBaseline)
+		}
+	}
+
+	os.Exit(exitCode(runs, interrupted))
+}
+
+// exitCode returns 0 if every test passed and met its thresholds. Test
+// failures take precedence over threshold violations, since the metrics of a
+// failed test can't be trusted.
+func exitCode(runs []*RunResult, interrupted bool) int {
+	if interrupted {
+		return exitInterrupted
+	}
+	violations := 0
+	for _, run := range runs {
+		if failedTests(run) > 0 {
+			return exitTestFailure
+		}
+		violations += len(run.Violations)
+	}
+	if violations > 0 {
+		return exitThresholdViolation
+	}
+	return 0
+}
+
+// registerTests returns every available test.
+func registerTests(assets *AssetConfig, videoCache *VideoCache) []Test {
+	allTests := []Test{
+		&MotionMarkTest{url: assets.ResolveURL(motionMarkURL)},
+		&SpeedometerTest{bundle: &BenchmarkBundle{Name: "speedometer-3.0", URL: speedometerBundleURL, assets: assets}},
+		&JetStreamTest{bundle: &BenchmarkBundle{Name: "jetstream-2.2", URL: jetStreamBundleURL, assets: assets}},
+		&ScrollTest{},
+	}
+
+	// Add video tests with local paths
+	for _, videoInfo := range testVideos {
+		localPath := videoCache.GetVideoPath(videoInfo)
+		allTests = append(allTests, &VideoTest{
+			name:       videoInfo.Name,
+			videoURL:   "file://" + localPath,
+			resolution: videoInfo.Resolution,
+		})
+	}
+
+	return allTests
+}
+
+// prepareAssets downloads the videos and benchmark bundles needed by tests,
+// or in offline mode checks that everything they need is already cached.
+func prepareAssets(tests []Test, assets *AssetConfig, videoCache *VideoCache) error {
+	if assets.Offline {
+		return checkOfflineAssets(tests, videoCache)
+	}
+
 	// Check if any video tests are included
 	hasVideoTests := false
-	for _, test := range harness.tests {
+	for _, test := range tests {
 		if strings.HasPrefix(test.Name(), "video-") {
 			hasVideoTests = true
 			break
@@ -121,16 +548,37 @@ func main() {
 	// Download videos if needed
 	if hasVideoTests {
 		if err := videoCache.EnsureAllVideos(); err != nil {
-			log.Fatalf("Failed to download test videos: %v", err)
+			return fmt.Errorf("failed to download test videos: %w", err)
 		}
-		fmt.Println()
+		fmt.Fprintln(assets.output())
 	}
 
-	// Run tests
-	results := harness.RunTests()
+	for _, test := range tests {
+		if bundled, ok := test.(BundledTest); ok {
+			if err := bundled.Bundle().Ensure(); err != nil {
+				return fmt.Errorf("failed to download %s: %w", bundled.Bundle().Name, err)
+			}
+		}
+	}
+	return nil
+}
 
-	// Print summary
-	printSummary(results)
+// interruptContext returns a context that is cancelled on the first Ctrl-C or
+// SIGTERM, so runs can stop gracefully. A second Ctrl-C exits immediately.
+func interruptContext(out io.Writer) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			fmt.Fprintln(out, "\nInterrupted, stopping after cleanup (press Ctrl-C again to force quit)")
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+		cancel()
+	}()
+	return ctx, cancel
 }
 
 func filterTests(allTests []Test, include, exclude string) []Test {
@@ -168,54 +616,161 @@ func filterTests(allTests []Test, include, exclude string) []Test {
 	return filtered
 }
 
-func (h *TestHarness) RunTests() []TestResult {
-	var results []TestResult
+// parseTestTimeouts parses a comma-separated list of name=duration pairs.
+func parseTestTimeouts(list string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	if list == "" {
+		return timeouts, nil
+	}
 
-	// Create Chrome options
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", h.headless),
-		chromedp.Flag("disable-blink-features", "AutomationControlled"),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("no-sandbox", true),
-	)
+	for _, entry := range strings.Split(list, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid test timeout %q, expected name=duration", entry)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for %s: %v", name, err)
+		}
+		timeouts[name] = d
+	}
+	return timeouts, nil
+}
 
-	// Add custom Chrome flags
-	for _, flag := range h.chromeFlags {
-		// Remove leading dashes if present
-		flag = strings.TrimLeft(flag, "-")
+// defaultTestTimeout is the -timeout default.
+const defaultTestTimeout = 20 * time.Minute
 
-		parts := strings.SplitN(flag, "=", 2)
-		if len(parts) == 2 {
-			opts = append(opts, chromedp.Flag(parts[0], parts[1]))
-		} else {
-			opts = append(opts, chromedp.Flag(parts[0], true))
-		}
+// timeoutFor returns the timeout for test: a -test-timeout override, else the
+// test's own Timeout, else the -timeout default.
+func (h *TestHarness) timeoutFor(test Test) time.Duration {
+	if d, ok := h.testTimeouts[test.Name()]; ok {
+		return d
+	}
+	if t, ok := test.(TimeoutTest); ok {
+		return t.Timeout()
 	}
+	return h.timeout
+}
 
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+// RunTests runs every selected test. Cancelling ctx aborts the current test
+// and returns the results recorded so far.
+func (h *TestHarness) RunTests(ctx context.Context) (*RunResult, error) {
+	run := &RunResult{
+		Label:       h.label,
+		ChromePath:  h.execPath,
+		StartTime:   time.Now(),
+		Isolation:   h.isolation,
+		RemoteURL:   h.remoteURL,
+		ChromeFlags: h.chromeFlags,
+	}
 
-	ctx, cancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
-	defer cancel()
+	var testNames []string
+	for _, test := range h.tests {
+		testNames = append(testNames, test.Name())
+	}
+	h.emit(Event{Type: EventRunStarted, Tests: testNames, Message: h.execPath})
 
-	// Get GPU info first
-	printGPUInfo(ctx)
+	// Make sure the host is quiet before launching Chrome
+	preflight, err := RunPreflight(ctx, h.out, h.preflight)
+	if err != nil {
+		h.emit(Event{Type: EventRunFinished, Message: err.Error()})
+		return nil, err
+	}
+	run.Preflight = preflight
+
+	session, err := h.launchBrowser()
+	if err != nil {
+		err = fmt.Errorf("launching browser: %w", err)
+		h.emit(Event{Type: EventRunFinished, Message: err.Error()})
+		return nil, err
+	}
+	defer func() {
+		if session != nil {
+			session.Close()
+		}
+	}()
+
+	// Capture browser, GPU and host info first
+	env, err := CaptureEnvironment(session.ctx)
+	if err != nil {
+		log.Printf("Failed to query browser environment: %v", err)
+	}
+	env.Print(h.out)
+	run.Environment = env
+	h.emit(Event{Type: EventEnvironmentCaptured, Environment: env})
+	fmt.Fprintf(h.out, "Test isolation: %s\n\n", h.isolation)
 
 	// Run each test
-	for _, test := range h.tests {
-		fmt.Printf("Running test: %s\n", test.Name())
+	fresh := true
+	for i, test := range h.tests {
+		// Let the host cool down so run order doesn't skew results
+		var cooldown time.Duration
+		if i > 0 && h.cooldownTemp > 0 {
+			cooldown = CoolDown(ctx, h.out, h.cooldownTemp, h.cooldownTimeout)
+		}
 
-		// Create a new context for each test with timeout
-		testCtx, testCancel := context.WithTimeout(ctx, 20*time.Minute)
+		if ctx.Err() != nil {
+			run.Interrupted = true
+			break
+		}
+
+		// Give each test its own browser or tab when isolating
+		if h.isolation == IsolateBrowser && !fresh {
+			session.Close()
+			if session, err = h.launchBrowser(); err != nil {
+				fmt.Fprintf(h.out, "Failed to launch browser: %v\n", err)
+				h.failTests(run, h.tests[i:], fmt.Errorf("launching browser: %w", err))
+				break
+			}
+		}
+		fresh = false
 
-		// Start Chrome-specific CPU monitoring
-		cpuMonitor := NewChromeCPUMonitor()
-		cpuMonitor.Start()
+		tabCtx, closeTab := session.ctx, context.CancelFunc(func() {})
+		if h.isolation == IsolateTab {
+			if tabCtx, closeTab, err = session.newTab(); err != nil {
+				fmt.Fprintf(h.out, "Failed to open tab, using shared tab: %v\n", err)
+				tabCtx, closeTab = session.ctx, func() {}
+			}
+		}
+
+		fmt.Fprintf(h.out, "Running test: %s\n", test.Name())
+		h.emit(Event{Type: EventTestStarted, Test: test.Name()})
+
+		// Create a new context for each test with timeout
+		timeout := h.timeoutFor(test)
+		testCtx, testCancel := context.WithTimeout(tabCtx, timeout)
+		stopAbort := context.AfterFunc(ctx, testCancel)
+
+		// Abort the test as soon as the browser crashes
+		session.watch(testCancel)
+		gpuPID := session.gpuProcessID()
+		testStart := time.Now()
+
+		// Start metric collectors
+		collectors := startCollectors(testCtx, h.out, h.collectors, session)
+		stopStream := func() {}
+		for _, c := range collectors {
+			if cpuMonitor, ok := c.(*ChromeCPUMonitor); ok {
+				stopStream = h.streamCPUSamples(cpuMonitor, test.Name())
+			}
+		}
 
 		result, err := test.Run(testCtx)
+		// Classify timeouts by the context as the test left it, before
+		// stopping the collectors eats into what's left of the deadline
+		testCtxErr := testCtx.Err()
+
+		// Stop metric collectors
+		stopStream()
+		for _, c := range collectors {
+			c.Stop()
+		}
 
-		// Stop CPU monitoring
-		cpuMonitor.Stop()
+		if pid := session.gpuProcessID(); gpuPID != 0 && pid != 0 && pid != gpuPID {
+			session.crashed(fmt.Sprintf("GPU process restarted (pid %d -> %d)", gpuPID, pid))
+		}
+		session.watch(nil)
+		stopAbort()
 
 		if result == nil {
 			result = &TestResult{
@@ -227,94 +782,158 @@ func (h *TestHarness) RunTests() []TestResult {
 			}
 		}
 
-		result.CPUSamples = cpuMonitor.GetSamples()
-		results = append(results, *result)
+		if result.Metrics == nil {
+			result.Metrics = make(map[string]interface{})
+		}
+		result.Samples = make(map[string][]Sample)
+		for _, c := range collectors {
+			samples, metrics := c.Results()
+			result.Samples[c.Name()] = samples
+			for key, value := range metrics {
+				result.Metrics[key] = value
+			}
+			if cpuMonitor, ok := c.(*ChromeCPUMonitor); ok {
+				result.CPUSamples = cpuMonitor.GetSamples()
+			}
+		}
+		result.Throttled, _ = result.Metrics["thermal_throttled"].(bool)
+		if cooldown > 0 {
+			result.Metrics["cooldown_seconds"] = cooldown.Seconds()
+		}
 
-		testCancel()
-		fmt.Println()
-	}
+		if ctx.Err() != nil {
+			result.Success = false
+			result.Aborted = true
+			result.Error = fmt.Errorf("aborted: run interrupted")
+			run.Interrupted = true
+			run.Results = append(run.Results, *result)
+			h.testFinished(run, result)
+			testCancel()
+			closeTab()
+			break
+		}
 
-	return results
-}
+		if testCtxErr == context.DeadlineExceeded && session.CrashReason() == "" {
+			result.Success = false
+			result.TimedOut = true
+			result.Error = fmt.Errorf("test timed out after %v", timeout)
+		}
 
-func printGPUInfo(ctx context.Context) {
-	// get gpu information
-	product := "unknown"
-	revision := "n/a"
-	params := []string{}
-	var gpu *systeminfo.GPUInfo
-	err := chromedp.Run(ctx,
-		chromedp.Navigate("about:blank"),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			c := chromedp.FromContext(ctx)
-			browserCtx := cdp.WithExecutor(ctx, c.Browser)
-			var err error
-			_, product, revision, _, _, err = browser.GetVersion().Do(browserCtx)
-			if err != nil {
-				return err
+		// Replace a crashed browser before moving on to the next test
+		var restartErr error
+		if reason := session.CrashReason(); reason != "" {
+			result.Success = false
+			result.Crashed = true
+			result.CrashReason = reason
+			result.Minidumps = session.collectMinidumps(testStart)
+			if result.Error == nil || testCtxErr != nil {
+				result.Error = fmt.Errorf("browser crashed: %s", reason)
 			}
-			params, err = browser.GetBrowserCommandLine().Do(browserCtx)
-			if err != nil {
-				return err
+
+			fmt.Fprintf(h.out, "Browser crashed (%s), restarting...\n", reason)
+			closeTab()
+			session.Close()
+			session, restartErr = h.launchBrowser()
+			if restartErr != nil {
+				fmt.Fprintf(h.out, "Failed to restart browser: %v\n", restartErr)
 			}
-			gpu, _, _, _, err = systeminfo.GetInfo().Do(browserCtx)
-			return err
-		}),
-	)
-	if err != nil {
-		log.Fatal(err)
+			fresh = true
+		}
+
+		run.Results = append(run.Results, *result)
+		h.testFinished(run, result)
+
+		testCancel()
+		closeTab()
+		fmt.Fprintln(h.out)
+
+		if restartErr != nil {
+			h.failTests(run, h.tests[i+1:], fmt.Errorf("restarting browser: %w", restartErr))
+			break
+		}
 	}
-	fmt.Printf("Browser: %s (%s)\n\n", product, revision)
-	fmt.Printf("Commandline: %v\n\n", params)
-	fmt.Println("\nGPU Information:")
-	if len(gpu.Devices) > 0 {
-		fmt.Printf("  GPU Vendor: %s\n", gpu.Devices[0].VendorString)
-		fmt.Printf("  GPU Device: %s\n", gpu.Devices[0].DeviceString)
-		fmt.Printf("  GPU Driver Version: %s\n", gpu.Devices[0].DriverVersion)
-	} else {
-		fmt.Printf("  GPU devices not available\n")
-	}
-	fmt.Println("\nGPU Feature Status:")
-	if gpu.FeatureStatus != nil {
-		var featureStatus map[string]string
-		err := json.Unmarshal(gpu.FeatureStatus, &featureStatus)
-		if err == nil {
-			// Sort feature keys alphabetically
-			keys := make([]string, 0, len(featureStatus))
-			for k := range featureStatus {
-				keys = append(keys, k)
-			}
-			sort.Strings(keys)
-			for _, feature := range keys {
-				fmt.Printf("  %s: %s\n", feature, featureStatus[feature])
-			}
-		} else {
-			fmt.Printf("  Error parsing gpu feature status: %v\n", err)
+
+	run.EndTime = time.Now()
+	failed := failedTests(run)
+	h.emit(Event{Type: EventRunFinished, Failed: &failed, Interrupted: run.Interrupted})
+	return run, nil
+}
+
+// failTests records tests as failed with err, so tests that couldn't run
+// because the browser couldn't be launched still fail the run.
+func (h *TestHarness) failTests(run *RunResult, tests []Test, err error) {
+	for _, test := range tests {
+		now := time.Now()
+		result := &TestResult{
+			TestName:  test.Name(),
+			StartTime: now,
+			EndTime:   now,
+			Success:   false,
+			Error:     err,
+			Metrics:   make(map[string]interface{}),
 		}
+		run.Results = append(run.Results, *result)
+		h.testFinished(run, result)
 	}
-	fmt.Println()
 }
 
-func printSummary(results []TestResult) {
-	fmt.Println("=== Test Summary ===")
-	fmt.Println()
+// testFinished reports a recorded result to the sinks and as events.
+func (h *TestHarness) testFinished(run *RunResult, result *TestResult) {
+	h.sinks.Send(run, result)
+	h.emitMetrics(result)
+	if h.onEvent != nil {
+		t := newHistoryTest(*result, nil)
+		h.emit(Event{Type: EventTestFinished, Test: result.TestName, Result: &t})
+	}
+}
+
+func printSummary(w io.Writer, run *RunResult) {
+	if run.Label != "" {
+		browser := ""
+		if run.Environment != nil {
+			browser = run.Environment.Browser
+		}
+		fmt.Fprintf(w, "=== Test Summary: %s (%s) ===\n", run.Label, browser)
+	} else {
+		fmt.Fprintln(w, "=== Test Summary ===")
+	}
+	fmt.Fprintln(w)
 
-	for _, result := range results {
-		fmt.Printf("Test: %s\n", result.TestName)
-		fmt.Printf("  Duration: %v\n", result.EndTime.Sub(result.StartTime))
-		fmt.Printf("  Success: %v\n", result.Success)
+	for _, result := range run.Results {
+		fmt.Fprintf(w, "Test: %s\n", result.TestName)
+		fmt.Fprintf(w, "  Duration: %v\n", result.EndTime.Sub(result.StartTime))
+		fmt.Fprintf(w, "  Success: %v\n", result.Success)
 
 		if result.Error != nil {
-			fmt.Printf("  Error: %v\n", result.Error)
+			fmt.Fprintf(w, "  Error: %v\n", result.Error)
 		}
 
 		if len(result.CPUSamples) > 0 {
 			avgCPU := calculateAverageCPU(result.CPUSamples)
-			fmt.Printf("  Average CPU Usage: %.2f%%\n", avgCPU)
+			fmt.Fprintf(w, "  Average CPU Usage: %.2f%%\n", avgCPU)
+		}
+
+		if result.TimedOut {
+			fmt.Fprintf(w, "  Timed Out: true\n")
+		}
+
+		if result.Aborted {
+			fmt.Fprintf(w, "  Aborted: true\n")
+		}
+
+		if result.Crashed {
+			fmt.Fprintf(w, "  Crashed: %s\n", result.CrashReason)
+			for _, dump := range result.Minidumps {
+				fmt.Fprintf(w, "  Minidump: %s\n", dump)
+			}
+		}
+
+		if result.Throttled {
+			fmt.Fprintf(w, "  Warning: thermal throttling occurred during this test\n")
 		}
 
 		if len(result.Metrics) > 0 {
-			fmt.Println("  Metrics:")
+			fmt.Fprintln(w, "  Metrics:")
 			// Sort keys alphabetically
 			keys := make([]string, 0, len(result.Metrics))
 			for key := range result.Metrics {
@@ -322,11 +941,11 @@ func printSummary(results []TestResult) {
 			}
 			sort.Strings(keys)
 			for _, key := range keys {
-				fmt.Printf("    %s: %v\n", key, result.Metrics[key])
+				fmt.Fprintf(w, "    %s: %v\n", key, result.Metrics[key])
 			}
 		}
 
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 }
 
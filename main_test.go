@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type namedTest string
+
+func (t namedTest) Name() string { return string(t) }
+
+func (t namedTest) Run(ctx context.Context) (*TestResult, error) { return nil, nil }
+
+func TestTimeoutFor(t *testing.T) {
+	h := &TestHarness{
+		timeout:      5 * time.Minute,
+		testTimeouts: map[string]time.Duration{"motionmark": 4 * time.Minute, "custom": time.Minute},
+	}
+	tests := []struct {
+		test Test
+		want time.Duration
+	}{
+		{&MotionMarkTest{}, 4 * time.Minute},
+		{&JetStreamTest{}, 20 * time.Minute},
+		{&ScrollTest{}, 2 * time.Minute},
+		{namedTest("custom"), time.Minute},
+		{namedTest("other"), 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := h.timeoutFor(tt.test); got != tt.want {
+			t.Errorf("timeoutFor(%s) = %v, want %v", tt.test.Name(), got, tt.want)
+		}
+	}
+}
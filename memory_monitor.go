@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MemoryMonitor samples the resident memory of all Chrome processes.
+type MemoryMonitor struct {
+	*poller
+	unavailable error
+}
+
+func NewMemoryMonitor() *MemoryMonitor {
+	return &MemoryMonitor{poller: newPoller(1*time.Second, sampleChromeMemory)}
+}
+
+// newMemoryMonitorFor returns a monitor for the browser in b. A remote browser
+// is only monitored when it runs on this host.
+func newMemoryMonitorFor(b *browserSession) *MemoryMonitor {
+	m := NewMemoryMonitor()
+	if b.remote {
+		if b.localPID == 0 {
+			m.unavailable = fmt.Errorf("remote browser is not on this host")
+		} else {
+			m.poller = newPoller(1*time.Second, func() (map[string]float64, error) {
+				return sampleProcessTreeMemory(b.localPID)
+			})
+		}
+	}
+	return m
+}
+
+func (m *MemoryMonitor) Name() string {
+	return "memory"
+}
+
+func (m *MemoryMonitor) Start(ctx context.Context) error {
+	if m.unavailable != nil {
+		return m.unavailable
+	}
+	if _, err := m.sample(); err != nil {
+		return err
+	}
+	m.start()
+	return nil
+}
+
+func (m *MemoryMonitor) Results() ([]Sample, map[string]interface{}) {
+	samples := m.getSamples()
+	metrics := make(map[string]interface{})
+	if len(samples) > 0 {
+		avg, peak := summarizeSamples(samples, "rss_mb")
+		metrics["memory_avg_mb"] = avg
+		metrics["memory_peak_mb"] = peak
+	}
+	return samples, metrics
+}
+
+func sampleChromeMemory() (map[string]float64, error) {
+	var rssBytes uint64
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		rssBytes, err = chromeRSSLinux()
+	case "darwin":
+		rssBytes, err = chromeRSSDarwin()
+	case "windows":
+		rssBytes, err = chromeRSSWindows()
+	default:
+		err = fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{"rss_mb": float64(rssBytes) / (1024 * 1024)}, nil
+}
+
+// sampleProcessTreeMemory sums the resident memory of root and its
+// descendants.
+func sampleProcessTreeMemory(root int) (map[string]float64, error) {
+	tree, err := processTree(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// ps reports RSS in kilobytes
+	output, err := exec.Command("ps", "-A", "-o", "pid=,rss=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields[0])
+		if tree[pid] {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			total += kb * 1024
+		}
+	}
+	return map[string]float64{"rss_mb": float64(total) / (1024 * 1024)}, nil
+}
+
+func chromeRSSLinux() (uint64, error) {
+	commFiles, err := filepath.Glob("/proc/[0-9]*/comm")
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	for _, commFile := range commFiles {
+		comm, err := os.ReadFile(commFile)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSpace(string(comm))
+		if !strings.Contains(name, "chrome") && !strings.Contains(name, "chromium") {
+			continue
+		}
+
+		status, err := readKeyValueFile(filepath.Join(filepath.Dir(commFile), "status"), ":")
+		if err != nil {
+			continue
+		}
+		// VmRSS is reported as "<n> kB"
+		fields := strings.Fields(status["VmRSS"])
+		if len(fields) > 0 {
+			kb, _ := strconv.ParseUint(fields[0], 10, 64)
+			total += kb * 1024
+		}
+	}
+	return total, nil
+}
+
+func chromeRSSDarwin() (uint64, error) {
+	output, err := exec.Command("ps", "-A", "-o", "rss,command").Output()
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		cmdLine := strings.Join(fields[1:], " ")
+		if strings.Contains(cmdLine, "Chromium") || strings.Contains(cmdLine, "Google Chrome") ||
+			strings.Contains(cmdLine, "chrome") {
+			kb, _ := strconv.ParseUint(fields[0], 10, 64)
+			total += kb * 1024
+		}
+	}
+	return total, nil
+}
+
+func chromeRSSWindows() (uint64, error) {
+	output, err := exec.Command("wmic", "process", "where", "name like '%chrome%'", "get", "WorkingSetSize", "/format:csv").Output()
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) >= 2 {
+			bytes, err := strconv.ParseUint(fields[1], 10, 64)
+			if err == nil {
+				total += bytes
+			}
+		}
+	}
+	return total, nil
+}
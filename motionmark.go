@@ -8,12 +8,24 @@ import (
 	"github.com/chromedp/chromedp"
 )
 
-type MotionMarkTest struct{}
+const motionMarkURL = "https://browserbench.org/MotionMark/"
+
+type MotionMarkTest struct {
+	url string
+}
 
 func (t *MotionMarkTest) Name() string {
 	return "motionmark"
 }
 
+func (t *MotionMarkTest) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+func (t *MotionMarkTest) RemoteURLs() []string {
+	return []string{t.url}
+}
+
 func (t *MotionMarkTest) Run(ctx context.Context) (*TestResult, error) {
 	result := &TestResult{
 		TestName:  t.Name(),
@@ -25,7 +37,7 @@ func (t *MotionMarkTest) Run(ctx context.Context) (*TestResult, error) {
 	var subtestNames, subtestScores, subtestConfidences []string
 
 	err := chromedp.Run(ctx,
-		chromedp.Navigate("https://browserbench.org/MotionMark/"),
+		chromedp.Navigate(t.url),
 		chromedp.WaitVisible(`#intro`),
 		chromedp.Evaluate(`benchmarkController.startBenchmark()`, nil),
 		chromedp.WaitVisible(`#results`, chromedp.ByID),
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricFamily is a gauge and its samples in the Prometheus text format.
+type metricFamily struct {
+	Name    string
+	Help    string
+	Samples []metricSample
+}
+
+type metricSample struct {
+	Labels [][2]string
+	Value  float64
+}
+
+// resultFamilies converts runs into metric families. Every test metric is a
+// sample of chromebench_metric with the metric name as a label, so dashboards
+// can select metrics without knowing them in advance.
+func resultFamilies(runs []*RunResult) []*metricFamily {
+	metric := &metricFamily{Name: "chromebench_metric", Help: "Metric reported by a chromebench test."}
+	success := &metricFamily{Name: "chromebench_test_success", Help: "Whether the test succeeded (1) or failed (0)."}
+	duration := &metricFamily{Name: "chromebench_test_duration_seconds", Help: "How long the test took to run."}
+	violations := &metricFamily{Name: "chromebench_test_threshold_violations", Help: "Number of thresholds the test didn't meet."}
+	timestamp := &metricFamily{Name: "chromebench_run_timestamp_seconds", Help: "Unix time the run started."}
+
+	host, _ := os.Hostname()
+	for _, run := range runs {
+		browser := ""
+		if run.Environment != nil {
+			browser = run.Environment.Browser
+		}
+		runLabels := [][2]string{
+			{"chrome_version", browser},
+			{"configuration", run.Label},
+			{"flags", strings.Join(run.ChromeFlags, " ")},
+			{"host", host},
+		}
+		timestamp.Samples = append(timestamp.Samples, metricSample{runLabels, float64(run.StartTime.Unix())})
+
+		for _, result := range run.Results {
+			testLabels := append([][2]string{{"test", result.TestName}}, runLabels...)
+
+			success.Samples = append(success.Samples, metricSample{testLabels, boolValue(result.Success)})
+			duration.Samples = append(duration.Samples, metricSample{testLabels, result.EndTime.Sub(result.StartTime).Seconds()})
+
+			count := 0
+			for _, v := range run.Violations {
+				if v.TestName == result.TestName {
+					count++
+				}
+			}
+			violations.Samples = append(violations.Samples, metricSample{testLabels, float64(count)})
+
+			// Sort keys alphabetically
+			keys := make([]string, 0, len(result.Metrics))
+			for key := range result.Metrics {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				value, ok := numericValue(result.Metrics[key])
+				if b, isBool := result.Metrics[key].(bool); isBool {
+					value, ok = boolValue(b), true
+				}
+				if !ok {
+					continue
+				}
+				labels := append([][2]string{{"test", result.TestName}, {"metric", key}}, runLabels...)
+				metric.Samples = append(metric.Samples, metricSample{labels, value})
+			}
+		}
+	}
+	return []*metricFamily{metric, success, duration, violations, timestamp}
+}
+
+// writeMetrics writes families in the text exposition format. With eof set
+// the output is terminated with "# EOF" as OpenMetrics requires; Prometheus
+// parsers treat it as a comment.
+func writeMetrics(w io.Writer, families []*metricFamily, eof bool) error {
+	var b strings.Builder
+	for _, f := range families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.Name, f.Help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", f.Name)
+		for _, s := range f.Samples {
+			b.WriteString(f.Name)
+			b.WriteString("{")
+			for i, label := range s.Labels {
+				if i > 0 {
+					b.WriteString(",")
+				}
+				fmt.Fprintf(&b, "%s=\"%s\"", label[0], escapeLabelValue(label[1]))
+			}
+			b.WriteString("} ")
+			b.WriteString(formatSampleValue(s.Value))
+			b.WriteString("\n")
+		}
+	}
+	if eof {
+		b.WriteString("# EOF\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeOpenMetricsFile writes the results to path for node_exporter's textfile
+// collector. The file is replaced atomically so the collector never reads a
+// partial file.
+func writeOpenMetricsFile(path string, runs []*RunResult) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := writeMetrics(f, resultFamilies(runs), true); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// pushMetrics replaces the metrics of this job and host on a Pushgateway.
+func pushMetrics(gatewayURL, job string, runs []*RunResult) error {
+	host, _ := os.Hostname()
+	target := strings.TrimRight(gatewayURL, "/") + "/metrics/job/" + url.PathEscape(job) + "/instance/" + url.PathEscape(host)
+
+	var body bytes.Buffer
+	if err := writeMetrics(&body, resultFamilies(runs), false); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func escapeLabelValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func formatSampleValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
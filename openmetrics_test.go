@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushMetrics(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	runs := []*RunResult{{
+		Label:     "stable",
+		StartTime: start,
+		Results: []TestResult{{
+			TestName:  "basic",
+			StartTime: start,
+			EndTime:   start.Add(2 * time.Second),
+			Success:   true,
+			Metrics:   map[string]interface{}{"load_ms": 12.5},
+		}},
+	}}
+	host, _ := os.Hostname()
+
+	var method, path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.EscapedPath()
+		contentType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	if err := pushMetrics(server.URL+"/", "nightly bench", runs); err != nil {
+		t.Fatalf("pushMetrics: %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	wantPath := "/metrics/job/nightly%20bench/instance/" + url.PathEscape(host)
+	if path != wantPath {
+		t.Errorf("path = %s, want %s", path, wantPath)
+	}
+	if contentType != "text/plain; version=0.0.4" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	for _, want := range []string{
+		"# TYPE chromebench_metric gauge\n",
+		`chromebench_metric{test="basic",metric="load_ms",chrome_version="",configuration="stable",flags="",host="` + escapeLabelValue(host) + `"} 12.5` + "\n",
+		`chromebench_test_success{test="basic",`,
+		"chromebench_run_timestamp_seconds{",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "# EOF") {
+		t.Errorf("body has an OpenMetrics EOF marker:\n%s", body)
+	}
+}
+
+func TestPushMetricsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad metric", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := pushMetrics(server.URL, "job", nil)
+	if err == nil {
+		t.Fatal("pushMetrics succeeded on a 400 response")
+	}
+	if !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "bad metric") {
+		t.Errorf("error = %q, want status and response body", err)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{`say "hi"`, `say \"hi\"`},
+		{`C:\chrome`, `C:\\chrome`},
+		{"two\nlines", `two\nlines`},
+		{`\"`, `\\\"`},
+	}
+	for _, tt := range tests {
+		if got := escapeLabelValue(tt.in); got != tt.want {
+			t.Errorf("escapeLabelValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSampleValue(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{-3, "-3"},
+		{12.5, "12.5"},
+		{1e15, "1e+15"},
+		{1.5e-7, "1.5e-07"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+	}
+	for _, tt := range tests {
+		if got := formatSampleValue(tt.in); got != tt.want {
+			t.Errorf("formatSampleValue(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
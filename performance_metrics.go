@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/performance"
+	"github.com/chromedp/chromedp"
+)
+
+// performanceMetricNames are the CDP Performance.getMetrics values recorded
+// for every test. Values ending in Duration are seconds.
+var performanceMetricNames = []string{
+	"TaskDuration",
+	"TaskOtherDuration",
+	"ScriptDuration",
+	"V8CompileDuration",
+	"LayoutDuration",
+	"RecalcStyleDuration",
+	"LayoutCount",
+	"RecalcStyleCount",
+	"ProcessTime",
+	"ThreadTime",
+	"JSHeapUsedSize",
+	"JSHeapTotalSize",
+	"JSEventListeners",
+	"Nodes",
+	"Documents",
+	"Frames",
+	"LayoutObjects",
+}
+
+// PerformanceCollector records the renderer-side cost of a test from CDP
+// Performance domain metrics: the change between Start and Stop for
+// cumulative counters, and the value at Stop for gauges.
+type PerformanceCollector struct {
+	ctx    context.Context
+	before map[string]float64
+	after  map[string]float64
+}
+
+func NewPerformanceCollector() *PerformanceCollector {
+	return &PerformanceCollector{}
+}
+
+func (c *PerformanceCollector) Name() string {
+	return "performance"
+}
+
+func (c *PerformanceCollector) Start(ctx context.Context) error {
+	c.ctx = ctx
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := performance.Enable().Do(ctx); err != nil {
+			return err
+		}
+		var err error
+		c.before, err = getPerformanceMetrics(ctx)
+		return err
+	}))
+}
+
+func (c *PerformanceCollector) Stop() {
+	// The test's context may have expired or be about to, so read the final
+	// metrics on a short context of their own
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.ctx), 5*time.Second)
+	defer cancel()
+
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		after, err := getPerformanceMetrics(ctx)
+		if err != nil {
+			return err
+		}
+		c.after = after
+		return performance.Disable().Do(ctx)
+	}))
+	if err != nil {
+		log.Printf("Failed to read performance metrics: %v", err)
+	}
+}
+
+func (c *PerformanceCollector) Results() ([]Sample, map[string]interface{}) {
+	metrics := make(map[string]interface{})
+	if c.after == nil {
+		return nil, metrics
+	}
+
+	for _, name := range performanceMetricNames {
+		end, ok := c.after[name]
+		if !ok {
+			continue
+		}
+		if !cumulativePerformanceMetric(name) {
+			metrics["perf_"+name] = end
+			continue
+		}
+		delta := end - c.before[name]
+		// Counters restart when a navigation swaps the renderer process, in
+		// which case everything counted so far belongs to the test.
+		if delta < 0 {
+			delta = end
+		}
+		metrics["perf_"+name] = delta
+	}
+	return nil, metrics
+}
+
+// cumulativePerformanceMetric reports whether name only grows over the life
+// of a renderer. The rest, such as JSHeapUsedSize, Nodes and Frames (the
+// number of frames in the page), are gauges.
+func cumulativePerformanceMetric(name string) bool {
+	return strings.HasSuffix(name, "Duration") || strings.HasSuffix(name, "Count") ||
+		name == "ProcessTime" || name == "ThreadTime"
+}
+
+func getPerformanceMetrics(ctx context.Context) (map[string]float64, error) {
+	list, err := performance.GetMetrics().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(list))
+	for _, m := range list {
+		values[m.Name] = m.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestPerformanceCollectorResults(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[string]float64
+		want          map[string]float64
+	}{
+		{
+			name:   "counters report the change",
+			before: map[string]float64{"ScriptDuration": 0.5, "LayoutCount": 10, "ThreadTime": 1},
+			after:  map[string]float64{"ScriptDuration": 2, "LayoutCount": 25, "ThreadTime": 3.5},
+			want:   map[string]float64{"ScriptDuration": 1.5, "LayoutCount": 15, "ThreadTime": 2.5},
+		},
+		{
+			name:   "counters reset by a new renderer",
+			before: map[string]float64{"TaskDuration": 4, "RecalcStyleCount": 100},
+			after:  map[string]float64{"TaskDuration": 1, "RecalcStyleCount": 30},
+			want:   map[string]float64{"TaskDuration": 1, "RecalcStyleCount": 30},
+		},
+		{
+			name:   "gauges report the end value",
+			before: map[string]float64{"JSHeapUsedSize": 8e6, "Nodes": 500, "Documents": 3, "Frames": 1, "LayoutObjects": 900},
+			after:  map[string]float64{"JSHeapUsedSize": 2e6, "Nodes": 120, "Documents": 1, "Frames": 2, "LayoutObjects": 150},
+			want:   map[string]float64{"JSHeapUsedSize": 2e6, "Nodes": 120, "Documents": 1, "Frames": 2, "LayoutObjects": 150},
+		},
+		{
+			name:   "missing end snapshot",
+			before: map[string]float64{"ScriptDuration": 1, "Nodes": 10},
+			after:  nil,
+			want:   map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		c := &PerformanceCollector{before: tt.before, after: tt.after}
+		_, metrics := c.Results()
+		if len(metrics) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, metrics, tt.want)
+			continue
+		}
+		for name, want := range tt.want {
+			if got := metrics["perf_"+name]; got != want {
+				t.Errorf("%s: perf_%s = %v, want %v", tt.name, name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var raplPackagePattern = regexp.MustCompile(`^intel-rapl:\d+$`)
+
+// PowerMonitor samples power draw on Linux, preferring the RAPL package energy
+// counters and falling back to the battery's reported discharge rate.
+type PowerMonitor struct {
+	*poller
+	raplDomains []string
+	battery     string
+	lastEnergy  map[string]float64
+	lastTime    time.Time
+}
+
+func NewPowerMonitor() *PowerMonitor {
+	m := &PowerMonitor{lastEnergy: make(map[string]float64)}
+	m.poller = newPoller(1*time.Second, m.sample)
+	return m
+}
+
+func (m *PowerMonitor) Name() string {
+	return "power"
+}
+
+func (m *PowerMonitor) Start(ctx context.Context) error {
+	domains, _ := filepath.Glob("/sys/class/powercap/intel-rapl:*")
+	for _, domain := range domains {
+		if !raplPackagePattern.MatchString(filepath.Base(domain)) {
+			continue
+		}
+		if energy, err := readSysfsFloat(filepath.Join(domain, "energy_uj")); err == nil {
+			m.raplDomains = append(m.raplDomains, domain)
+			m.lastEnergy[domain] = energy
+		}
+	}
+
+	if len(m.raplDomains) == 0 {
+		m.battery = findDischargingBattery()
+		if m.battery == "" {
+			return errors.New("no readable RAPL counters or discharging battery")
+		}
+	}
+
+	m.lastTime = time.Now()
+	m.start()
+	return nil
+}
+
+func (m *PowerMonitor) Results() ([]Sample, map[string]interface{}) {
+	samples := m.getSamples()
+	metrics := make(map[string]interface{})
+	if len(samples) > 0 {
+		avg, peak := summarizeSamples(samples, "watts")
+		metrics["power_avg_watts"] = avg
+		metrics["power_peak_watts"] = peak
+		duration := samples[len(samples)-1].Timestamp.Sub(samples[0].Timestamp) + m.interval
+		metrics["energy_joules"] = avg * duration.Seconds()
+	}
+	return samples, metrics
+}
+
+func (m *PowerMonitor) sample() (map[string]float64, error) {
+	if m.battery != "" {
+		microwatts, err := readSysfsFloat(filepath.Join(m.battery, "power_now"))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]float64{"watts": microwatts / 1e6}, nil
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(m.lastTime).Seconds()
+	m.lastTime = now
+
+	var joules float64
+	for _, domain := range m.raplDomains {
+		energy, err := readSysfsFloat(filepath.Join(domain, "energy_uj"))
+		if err != nil {
+			return nil, err
+		}
+		delta := energy - m.lastEnergy[domain]
+		if delta < 0 {
+			// The counter wrapped around
+			maxRange, _ := readSysfsFloat(filepath.Join(domain, "max_energy_range_uj"))
+			delta += maxRange
+		}
+		m.lastEnergy[domain] = energy
+		joules += delta / 1e6
+	}
+	return map[string]float64{"watts": joules / elapsed}, nil
+}
+
+func findDischargingBattery() string {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	for _, supply := range supplies {
+		supplyType, _ := os.ReadFile(filepath.Join(supply, "type"))
+		status, _ := os.ReadFile(filepath.Join(supply, "status"))
+		if strings.TrimSpace(string(supplyType)) != "Battery" || strings.TrimSpace(string(status)) != "Discharging" {
+			continue
+		}
+		if _, err := readSysfsFloat(filepath.Join(supply, "power_now")); err == nil {
+			return supply
+		}
+	}
+	return ""
+}
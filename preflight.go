@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Idle policies accepted by -require-idle.
+const (
+	IdlePolicyOff   = "off"
+	IdlePolicyWarn  = "warn"
+	IdlePolicyWait  = "wait"
+	IdlePolicyAbort = "abort"
+)
+
+const (
+	preflightSampleInterval = 1 * time.Second
+	preflightRetryInterval  = 5 * time.Second
+	// A process using more than this share of one core counts as heavy.
+	heavyProcessPercent = 25.0
+	// Any thermal zone above this temperature means the host is not idle.
+	preflightMaxTemp = 75.0
+)
+
+// PreflightConfig controls the host stability check run before any tests.
+type PreflightConfig struct {
+	Policy        string
+	MaxCPUPercent float64
+	Timeout       time.Duration
+}
+
+// PreflightReport records the state of the host before benchmarking.
+type PreflightReport struct {
+	Time          time.Time
+	Policy        string
+	Idle          bool
+	Waited        time.Duration
+	CPULoad       float64
+	Governors     []string
+	Temperatures  map[string]float64
+	BusyProcesses []ProcessLoad
+	Warnings      []string
+}
+
+type ProcessLoad struct {
+	PID     int
+	Name    string
+	Percent float64
+}
+
+func validIdlePolicy(policy string) bool {
+	switch policy {
+	case IdlePolicyOff, IdlePolicyWarn, IdlePolicyWait, IdlePolicyAbort:
+		return true
+	}
+	return false
+}
+
+// RunPreflight checks that the host is idle, applying the configured policy.
+// It returns an error only when the policy is abort, or wait and the host did
+// not become idle before the timeout.
+func RunPreflight(ctx context.Context, out io.Writer, cfg PreflightConfig) (*PreflightReport, error) {
+	if cfg.Policy == IdlePolicyOff {
+		return nil, nil
+	}
+
+	start := time.Now()
+	report := checkHost(cfg)
+
+	if cfg.Policy == IdlePolicyWait && !report.Idle {
+		deadline := start.Add(cfg.Timeout)
+		for !report.Idle && time.Now().Before(deadline) {
+			fmt.Fprintf(out, "Host not idle (CPU load %.1f%%), waiting...\n", report.CPULoad)
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			case <-time.After(preflightRetryInterval):
+			}
+			report = checkHost(cfg)
+		}
+	}
+	report.Waited = time.Since(start)
+
+	report.Print(out)
+
+	if !report.Idle && (cfg.Policy == IdlePolicyAbort || cfg.Policy == IdlePolicyWait) {
+		return report, fmt.Errorf("host is not idle (policy %q)", cfg.Policy)
+	}
+	return report, nil
+}
+
+func checkHost(cfg PreflightConfig) *PreflightReport {
+	report := &PreflightReport{
+		Time:   time.Now(),
+		Policy: cfg.Policy,
+		Idle:   true,
+	}
+
+	if runtime.GOOS != "linux" {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("pre-flight checks are not supported on %s", runtime.GOOS))
+		return report
+	}
+
+	// Take two snapshots of system and per-process CPU time
+	before, err := readProcStatCPU()
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("reading /proc/stat: %v", err))
+		return report
+	}
+	procsBefore := readProcessCPUTimes()
+	time.Sleep(preflightSampleInterval)
+	after, err := readProcStatCPU()
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("reading /proc/stat: %v", err))
+		return report
+	}
+	procsAfter := readProcessCPUTimes()
+
+	report.CPULoad = after.busyPercentSince(before)
+	if report.CPULoad > cfg.MaxCPUPercent {
+		report.Idle = false
+		report.Warnings = append(report.Warnings, fmt.Sprintf("system CPU load %.1f%% exceeds %.1f%%", report.CPULoad, cfg.MaxCPUPercent))
+	}
+
+	report.BusyProcesses = busyProcesses(procsBefore, procsAfter, preflightSampleInterval)
+	for _, p := range report.BusyProcesses {
+		report.Idle = false
+		report.Warnings = append(report.Warnings, fmt.Sprintf("process %s (%d) is using %.1f%% CPU", p.Name, p.PID, p.Percent))
+	}
+
+	report.Governors = readCPUGovernors()
+	for _, g := range report.Governors {
+		if g != "performance" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("CPU frequency governor is %q, not \"performance\"", g))
+		}
+	}
+
+	report.Temperatures = readThermalZones()
+	zones := make([]string, 0, len(report.Temperatures))
+	for zone := range report.Temperatures {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		if temp := report.Temperatures[zone]; temp > preflightMaxTemp {
+			report.Idle = false
+			report.Warnings = append(report.Warnings, fmt.Sprintf("thermal zone %s is at %.1f°C", zone, temp))
+		}
+	}
+
+	return report
+}
+
+// Print writes the pre-flight findings to w.
+func (r *PreflightReport) Print(w io.Writer) {
+	fmt.Fprintln(w, "Pre-flight Check:")
+	fmt.Fprintf(w, "  Idle: %v\n", r.Idle)
+	fmt.Fprintf(w, "  CPU Load: %.1f%%\n", r.CPULoad)
+	if r.Waited >= preflightRetryInterval {
+		fmt.Fprintf(w, "  Waited: %v\n", r.Waited.Round(time.Second))
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "  Warning: %s\n", warning)
+	}
+	fmt.Fprintln(w)
+}
+
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+func (t cpuTimes) busyPercentSince(prev cpuTimes) float64 {
+	total := t.total - prev.total
+	if total == 0 {
+		return 0
+	}
+	idle := t.idle - prev.idle
+	return float64(total-idle) / float64(total) * 100
+}
+
+// readProcStatCPU returns the aggregate CPU times from /proc/stat.
+func readProcStatCPU() (cpuTimes, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, err
+	}
+	return parseProcStatCPU(string(data))
+}
+
+// parseProcStatCPU parses the aggregate CPU times from the first line of
+// /proc/stat. Idle time includes iowait.
+func parseProcStatCPU(data string) (cpuTimes, error) {
+	line, _, _ := strings.Cut(data, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuTimes{}, fmt.Errorf("unexpected /proc/stat format")
+	}
+
+	var t cpuTimes
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return cpuTimes{}, err
+		}
+		// Fields are user, nice, system, idle, iowait, irq, softirq, steal, ...
+		// guest and guest_nice are already included in user and nice.
+		if i >= 8 {
+			break
+		}
+		t.total += v
+		if i == 3 || i == 4 {
+			t.idle += v
+		}
+	}
+	return t, nil
+}
+
+type processCPUTime struct {
+	name  string
+	ticks uint64
+}
+
+// readProcessCPUTimes returns the user+system clock ticks consumed by every
+// process, keyed by pid.
+func readProcessCPUTimes() map[int]processCPUTime {
+	times := make(map[int]processCPUTime)
+	statFiles, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, statFile := range statFiles {
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(statFile)))
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(statFile)
+		if err != nil {
+			continue
+		}
+		if t, ok := parseProcessStat(string(data)); ok {
+			times[pid] = t
+		}
+	}
+	return times
+}
+
+// parseProcessStat parses the command name and user+system clock ticks from
+// the contents of /proc/<pid>/stat.
+func parseProcessStat(s string) (processCPUTime, bool) {
+	// The command name is in parentheses and may itself contain spaces
+	lp, rp := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if lp < 0 || rp < lp {
+		return processCPUTime{}, false
+	}
+	fields := strings.Fields(s[rp+1:])
+	// utime and stime are fields 14 and 15 of the full line
+	if len(fields) < 13 {
+		return processCPUTime{}, false
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return processCPUTime{}, false
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return processCPUTime{}, false
+	}
+	return processCPUTime{name: s[lp+1 : rp], ticks: utime + stime}, true
+}
+
+func busyProcesses(before, after map[int]processCPUTime, interval time.Duration) []ProcessLoad {
+	// USER_HZ is 100 on all mainstream Linux architectures
+	const clockTicks = 100.0
+
+	self := os.Getpid()
+	var busy []ProcessLoad
+	for pid, a := range after {
+		b, ok := before[pid]
+		if !ok || pid == self || a.ticks < b.ticks {
+			continue
+		}
+		percent := float64(a.ticks-b.ticks) / clockTicks / interval.Seconds() * 100
+		if percent > heavyProcessPercent {
+			busy = append(busy, ProcessLoad{PID: pid, Name: a.name, Percent: percent})
+		}
+	}
+	sort.Slice(busy, func(i, j int) bool {
+		return busy[i].Percent > busy[j].Percent
+	})
+	return busy
+}
+
+// readCPUGovernors returns the distinct scaling governors in use.
+func readCPUGovernors() []string {
+	seen := make(map[string]bool)
+	var governors []string
+	files, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor")
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		g := strings.TrimSpace(string(data))
+		if !seen[g] {
+			seen[g] = true
+			governors = append(governors, g)
+		}
+	}
+	sort.Strings(governors)
+	return governors
+}
+
+// readThermalZones returns the current temperature in °C of every thermal
+// zone, keyed by "<zone>/<type>".
+func readThermalZones() map[string]float64 {
+	temps := make(map[string]float64)
+	zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+	for _, zone := range zones {
+		data, err := os.ReadFile(filepath.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(zone)
+		if zoneType, err := os.ReadFile(filepath.Join(zone, "type")); err == nil {
+			name += "/" + strings.TrimSpace(string(zoneType))
+		}
+		temps[name] = milli / 1000
+	}
+	return temps
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProcStatCPU(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    cpuTimes
+		wantErr bool
+	}{
+		{
+			name: "full line",
+			data: "cpu  10132153 290696 3084719 46828483 16683 0 25195 0 175628 0\ncpu0 1393280 32966 572056 13343292 6130 0 17875 0 23933 0\nintr 1 2 3\n",
+			// guest and guest_nice aren't counted again
+			want: cpuTimes{idle: 46828483 + 16683, total: 10132153 + 290696 + 3084719 + 46828483 + 16683 + 25195},
+		},
+		{
+			name: "old kernel without steal",
+			data: "cpu 100 0 50 800 50\n",
+			want: cpuTimes{idle: 850, total: 1000},
+		},
+		{name: "empty", data: "", wantErr: true},
+		{name: "too few fields", data: "cpu 1 2 3\n", wantErr: true},
+		{name: "not the cpu line", data: "intr 1 2 3 4 5\n", wantErr: true},
+		{name: "per-cpu line", data: "cpu0 1 2 3 4 5\n", wantErr: true},
+		{name: "bad number", data: "cpu 1 2 x 4 5\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseProcStatCPU(tt.data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: parseProcStatCPU = %+v, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseProcStatCPU: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: parseProcStatCPU = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCPUTimesBusyPercentSince(t *testing.T) {
+	prev := cpuTimes{idle: 800, total: 1000}
+	tests := []struct {
+		now  cpuTimes
+		want float64
+	}{
+		{cpuTimes{idle: 890, total: 1100}, 10},
+		{cpuTimes{idle: 800, total: 1200}, 100},
+		{cpuTimes{idle: 900, total: 1100}, 0},
+		{prev, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.now.busyPercentSince(prev); got != tt.want {
+			t.Errorf("%+v.busyPercentSince(%+v) = %v, want %v", tt.now, prev, got, tt.want)
+		}
+	}
+}
+
+func TestParseProcessStat(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		want   processCPUTime
+		wantOK bool
+	}{
+		{
+			name:   "plain",
+			data:   "1234 (chrome) S 1 1234 1234 0 -1 4194304 100 0 0 0 250 50 0 0 20 0 12 0 100 1000 100\n",
+			want:   processCPUTime{name: "chrome", ticks: 300},
+			wantOK: true,
+		},
+		{
+			name:   "name with spaces and parentheses",
+			data:   "42 (Web Content (x)) R 1 42 42 0 -1 0 0 0 0 0 7 3 0 0 20 0 1 0 5 100 10\n",
+			want:   processCPUTime{name: "Web Content (x)", ticks: 10},
+			wantOK: true,
+		},
+		{name: "no name", data: "42 R 1 42 42 0 -1 0 0 0 0 0 7 3\n"},
+		{name: "truncated", data: "42 (sh) S 1 42 42 0 -1 0 0 0 0 0 7\n"},
+		{name: "bad ticks", data: "42 (sh) S 1 42 42 0 -1 0 0 0 0 0 x 3\n"},
+		{name: "empty", data: ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseProcessStat(tt.data)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("%s: parseProcessStat = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBusyProcesses(t *testing.T) {
+	self := os.Getpid()
+	before := map[int]processCPUTime{
+		10:   {name: "indexer", ticks: 0},
+		11:   {name: "idle", ticks: 10},
+		13:   {name: "reused", ticks: 500},
+		14:   {name: "compiler", ticks: 1000},
+		self: {name: "chromebench", ticks: 0},
+	}
+	after := map[int]processCPUTime{
+		10:   {name: "indexer", ticks: 50},
+		11:   {name: "idle", ticks: 30},
+		12:   {name: "new", ticks: 100},
+		13:   {name: "reused", ticks: 5},
+		14:   {name: "compiler", ticks: 1100},
+		self: {name: "chromebench", ticks: 100},
+	}
+
+	got := busyProcesses(before, after, time.Second)
+	want := []ProcessLoad{
+		{PID: 14, Name: "compiler", Percent: 100},
+		{PID: 10, Name: "indexer", Percent: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("busyProcesses = %+v, want %+v", got, want)
+	}
+
+	// The same ticks over a longer interval are a smaller share of a core
+	if got := busyProcesses(before, after, 3*time.Second); len(got) != 1 || got[0].PID != 14 {
+		t.Errorf("busyProcesses over 3s = %+v, want only pid 14", got)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcessGroup starts Chrome in its own process group so a Ctrl-C in the
+// terminal reaches chromebench only, letting it shut Chrome down cleanly. The
+// browser is still killed if chromebench itself dies.
+func detachProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.SysProcAttr.Pdeathsig = syscall.SIGKILL
+}
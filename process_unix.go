@@ -0,0 +1,17 @@
+//go:build unix && !linux
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcessGroup starts Chrome in its own process group so a Ctrl-C in the
+// terminal reaches chromebench only, letting it shut Chrome down cleanly.
+func detachProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcessGroup starts Chrome in a new process group so a Ctrl-C in the
+// console reaches chromebench only, letting it shut Chrome down cleanly.
+func detachProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/systeminfo"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// connectRemote attaches to an already running browser over its remote
+// debugging endpoint and opens a new tab for the tests.
+func (h *TestHarness) connectRemote() (*browserSession, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(context.Background(), h.remoteURL)
+	ctx, cancelCtx := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
+
+	if err := chromedp.Run(ctx); err != nil {
+		cancelCtx()
+		cancelAlloc()
+		return nil, err
+	}
+
+	b := &browserSession{
+		ctx:     ctx,
+		remote:  true,
+		targets: make(map[target.ID]bool),
+		// With a remote allocator this closes our tab and the connection,
+		// leaving the browser running
+		cancel: func() {
+			cancelCtx()
+			cancelAlloc()
+		},
+	}
+	b.watchCrashes()
+
+	b.localPID = localBrowserPID(ctx, h.remoteURL)
+	if b.localPID == 0 {
+		fmt.Fprintln(h.out, "Remote browser is not running on this host; CPU and memory monitoring are disabled")
+	}
+
+	return b, nil
+}
+
+// localBrowserPID returns the pid of the remote browser's main process if it
+// is running on this host, or 0 otherwise.
+func localBrowserPID(ctx context.Context, remoteURL string) int {
+	u, err := url.Parse(remoteURL)
+	if err != nil || !isLocalHost(u.Hostname()) {
+		return 0
+	}
+
+	var pid int
+	chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		c := chromedp.FromContext(ctx)
+		processes, err := systeminfo.GetProcessInfo().Do(cdp.WithExecutor(ctx, c.Browser))
+		if err != nil {
+			return err
+		}
+		for _, p := range processes {
+			if p.Type == "browser" {
+				pid = int(p.ID)
+			}
+		}
+		return nil
+	}))
+
+	// A browser in a container reports a pid from its own namespace, so make
+	// sure the pid really is a Chrome process here.
+	if pid == 0 || !isChromeProcess(pid) {
+		return 0
+	}
+	return pid
+}
+
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := net.LookupIP(host)
+		if err != nil || len(addrs) == 0 {
+			return false
+		}
+		ip = addrs[0]
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isChromeProcess(pid int) bool {
+	var name string
+	switch runtime.GOOS {
+	case "linux":
+		comm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+		if err != nil {
+			return false
+		}
+		name = string(comm)
+	case "windows":
+		return false
+	default:
+		name = commandOutput("ps", "-p", strconv.Itoa(pid), "-o", "comm=")
+	}
+	name = strings.ToLower(name)
+	return strings.Contains(name, "chrome") || strings.Contains(name, "chromium")
+}
+
+// processTree returns root and all of its descendants.
+func processTree(root int) (map[int]bool, error) {
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("process tree monitoring is not supported on %s", runtime.GOOS)
+	}
+
+	output, err := exec.Command("ps", "-A", "-o", "pid=,ppid=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]int)
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields[0])
+		ppid, _ := strconv.Atoi(fields[1])
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	tree := map[int]bool{root: true}
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			if !tree[child] {
+				tree[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Output formats accepted by -format.
+const (
+	FormatText     = "text"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+func validFormat(format string) bool {
+	switch format {
+	case FormatText, FormatCSV, FormatMarkdown:
+		return true
+	}
+	return false
+}
+
+// resultTable flattens runs into one row per test and configuration. The
+// fixed columns come first, followed by every metric in sorted order, so the
+// columns are stable between runs with the same tests.
+func resultTable(runs []*RunResult, formatValue func(float64) string) (header []string, rows [][]string) {
+	keySet := make(map[string]bool)
+	for _, run := range runs {
+		for _, result := range run.Results {
+			for key := range result.Metrics {
+				keySet[key] = true
+			}
+		}
+	}
+
+	// Sort keys alphabetically
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	header = append([]string{"configuration", "browser", "flags", "test", "success", "duration_seconds", "error"}, keys...)
+
+	for _, run := range runs {
+		browser := ""
+		if run.Environment != nil {
+			browser = run.Environment.Browser
+		}
+		for _, result := range run.Results {
+			errText := ""
+			if result.Error != nil {
+				errText = result.Error.Error()
+			}
+			row := []string{
+				run.Label,
+				browser,
+				strings.Join(run.ChromeFlags, " "),
+				result.TestName,
+				strconv.FormatBool(result.Success),
+				formatValue(result.EndTime.Sub(result.StartTime).Seconds()),
+				errText,
+			}
+			for _, key := range keys {
+				value, ok := result.Metrics[key]
+				if !ok {
+					row = append(row, "")
+				} else if v, isNum := numericValue(value); isNum {
+					row = append(row, formatValue(v))
+				} else {
+					row = append(row, fmt.Sprint(value))
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return header, rows
+}
+
+// writeCSV writes the result table as CSV with full precision values.
+func writeCSV(w io.Writer, runs []*RunResult) error {
+	header, rows := resultTable(runs, func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	})
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+// writeMarkdown writes the result table as a GitHub flavoured Markdown table.
+func writeMarkdown(w io.Writer, runs []*RunResult) error {
+	header, rows := resultTable(runs, formatMetric)
+
+	escape := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			cell = strings.ReplaceAll(cell, "|", `\|`)
+			escaped[i] = strings.ReplaceAll(cell, "\n", " ")
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	lines := []string{escape(header), "|" + strings.Join(separator, "|") + "|"}
+	for _, row := range rows {
+		lines = append(lines, escape(row))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// writeTable writes runs as a csv or markdown table to path, or to stdout if
+// path is empty.
+func writeTable(format, path string, runs []*RunResult) error {
+	if path == "" {
+		return writeTableTo(os.Stdout, format, runs)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeTableTo(f, format, runs); err != nil {
+		f.Close()
+		return err
+	}
+	// A full disk may only show up when the file is closed
+	return f.Close()
+}
+
+func writeTableTo(w io.Writer, format string, runs []*RunResult) error {
+	if format == FormatCSV {
+		return writeCSV(w, runs)
+	}
+	return writeMarkdown(w, runs)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/tracing"
+	"github.com/chromedp/chromedp"
+)
+
+// scrollSpeeds are the gesture speeds in pixels per second, from a gentle
+// read-along scroll to a fast fling.
+var scrollSpeeds = []int64{800, 2000, 5000}
+
+// scrollGestureDuration is roughly how long each gesture lasts.
+const scrollGestureDuration = 4 * time.Second
+
+// scrollTraceCategories are the trace categories with DevTools' per-frame
+// events and the renderer process of each frame.
+var scrollTraceCategories = []string{
+	"disabled-by-default-devtools.timeline",
+	"disabled-by-default-devtools.timeline.frame",
+}
+
+// ScrollTest scrolls a long, content-heavy page with synthesized gestures at
+// several speeds and measures how smoothly it keeps up.
+type ScrollTest struct{}
+
+func (t *ScrollTest) Name() string {
+	return "scroll"
+}
+
+// Timeout allows for each gesture plus page load and settling.
+func (t *ScrollTest) Timeout() time.Duration {
+	return 2 * time.Minute
+}
+
+// scrollRun is what one gesture at one speed measured.
+type scrollRun struct {
+	speed         int64
+	frames        int
+	missedFrames  int
+	janks         int
+	maxFrameMs    float64
+	traceDrawn    int
+	traceDropped  int
+	traceComplete bool
+}
+
+func (t *ScrollTest) Run(ctx context.Context) (*TestResult, error) {
+	result := &TestResult{
+		TestName:  t.Name(),
+		StartTime: time.Now(),
+		Metrics:   make(map[string]interface{}),
+	}
+
+	// Create a temporary HTML file
+	tmpFile, err := os.CreateTemp("", "scroll-test-*.html")
+	if err != nil {
+		result.EndTime = time.Now()
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.WriteString(scrollPage()); err != nil {
+		result.EndTime = time.Now()
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+	tmpFile.Close()
+
+	trace := newTraceRecorder(ctx)
+
+	var vsyncMs float64
+	var runs []scrollRun
+	err = chromedp.Run(ctx,
+		chromedp.Navigate("file://"+tmpFile.Name()),
+		chromedp.WaitReady("body"),
+		chromedp.Sleep(time.Second),
+
+		// Measure the display's frame interval while nothing is happening
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var timestamps []float64
+			if err := chromedp.Evaluate(`window.startFrames()`, nil).Do(ctx); err != nil {
+				return err
+			}
+			if err := chromedp.Sleep(time.Second).Do(ctx); err != nil {
+				return err
+			}
+			if err := chromedp.Evaluate(`window.stopFrames()`, &timestamps).Do(ctx); err != nil {
+				return err
+			}
+			intervals := frameIntervals(timestamps)
+			if len(intervals) == 0 {
+				return fmt.Errorf("no animation frames while idle")
+			}
+			vsyncMs = median(intervals)
+			return nil
+		}),
+
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			for _, speed := range scrollSpeeds {
+				run, err := scrollAtSpeed(ctx, trace, speed, vsyncMs)
+				if err != nil {
+					return fmt.Errorf("scrolling at %d px/s: %w", speed, err)
+				}
+				runs = append(runs, run)
+			}
+			return nil
+		}),
+	)
+
+	result.EndTime = time.Now()
+
+	if err != nil {
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+
+	result.Metrics["refresh_rate_hz"] = 1000 / vsyncMs
+
+	var frames, missed, janks, drawn, dropped int
+	traced := false
+	for _, run := range runs {
+		prefix := fmt.Sprintf("speed_%d_", run.speed)
+		result.Metrics[prefix+"smoothness_percent"] = smoothnessPercent(run.frames, run.missedFrames)
+		result.Metrics[prefix+"jank_count"] = run.janks
+		result.Metrics[prefix+"max_frame_ms"] = run.maxFrameMs
+		if run.traceComplete {
+			result.Metrics[prefix+"dropped_frames"] = run.traceDropped
+			drawn += run.traceDrawn
+			dropped += run.traceDropped
+			traced = true
+		}
+		frames += run.frames
+		missed += run.missedFrames
+		janks += run.janks
+	}
+
+	if frames == 0 {
+		result.Success = false
+		result.Error = fmt.Errorf("no animation frames while scrolling")
+		return result, result.Error
+	}
+
+	result.Metrics["smoothness_percent"] = smoothnessPercent(frames, missed)
+	result.Metrics["jank_count"] = janks
+	if traced {
+		result.Metrics["dropped_frames"] = dropped
+		result.Metrics["trace_smoothness_percent"] = smoothnessPercent(drawn, dropped)
+	}
+
+	result.Success = true
+	return result, nil
+}
+
+// scrollAtSpeed scrolls from the top of the page at speed while recording
+// animation frames and a trace.
+func scrollAtSpeed(ctx context.Context, trace *traceRecorder, speed int64, vsyncMs float64) (scrollRun, error) {
+	run := scrollRun{speed: speed}
+
+	var page struct {
+		Width     float64
+		Height    float64
+		MaxScroll float64
+	}
+	err := chromedp.Evaluate(`window.scrollTo(0, 0), {
+		Width: window.innerWidth,
+		Height: window.innerHeight,
+		MaxScroll: document.documentElement.scrollHeight - window.innerHeight
+	}`, &page).Do(ctx)
+	if err != nil {
+		return run, err
+	}
+	distance := math.Min(float64(speed)*scrollGestureDuration.Seconds(), page.MaxScroll)
+
+	// Let the page settle after jumping back to the top
+	if err := chromedp.Sleep(500 * time.Millisecond).Do(ctx); err != nil {
+		return run, err
+	}
+
+	if err := trace.Start(ctx); err != nil {
+		return run, fmt.Errorf("starting trace: %w", err)
+	}
+
+	// A negative distance scrolls down
+	var timestamps []float64
+	err = chromedp.Run(ctx,
+		chromedp.Evaluate(`window.startFrames()`, nil),
+		input.SynthesizeScrollGesture(page.Width/2, page.Height/2).
+			WithYDistance(-distance).
+			WithSpeed(speed).
+			WithPreventFling(true),
+		chromedp.Evaluate(`window.stopFrames()`, &timestamps),
+	)
+	if err != nil {
+		// Don't leave the trace running
+		tracing.End().Do(ctx)
+		return run, err
+	}
+
+	events, complete, err := trace.Stop(ctx)
+	if err != nil {
+		return run, fmt.Errorf("stopping trace: %w", err)
+	}
+
+	for _, interval := range frameIntervals(timestamps) {
+		// Each whole vsync an interval spans beyond the first is a frame
+		// the page failed to produce
+		vsyncs := math.Max(1, math.Round(interval/vsyncMs))
+		run.frames++
+		run.missedFrames += int(vsyncs) - 1
+		if vsyncs >= 2 {
+			run.janks++
+		}
+		run.maxFrameMs = math.Max(run.maxFrameMs, interval)
+	}
+
+	if complete {
+		run.traceDrawn, run.traceDropped = countTraceFrames(events)
+		run.traceComplete = true
+	}
+	return run, nil
+}
+
+// frameIntervals returns the gaps between requestAnimationFrame timestamps.
+func frameIntervals(timestamps []float64) []float64 {
+	var intervals []float64
+	for i := 1; i < len(timestamps); i++ {
+		intervals = append(intervals, timestamps[i]-timestamps[i-1])
+	}
+	return intervals
+}
+
+func smoothnessPercent(presented, dropped int) float64 {
+	if presented+dropped == 0 {
+		return 0
+	}
+	return float64(presented) / float64(presented+dropped) * 100
+}
+
+// traceEvent is the part of a Chrome trace event the scroll test reads.
+type traceEvent struct {
+	Name string          `json:"name"`
+	Pid  int             `json:"pid"`
+	Args json.RawMessage `json:"args"`
+}
+
+// traceRecorder collects trace events reported over CDP for the tab behind
+// the context it was created with.
+type traceRecorder struct {
+	mu       sync.Mutex
+	events   []traceEvent
+	complete chan bool
+}
+
+func newTraceRecorder(ctx context.Context) *traceRecorder {
+	r := &traceRecorder{}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *tracing.EventDataCollected:
+			r.mu.Lock()
+			for _, raw := range ev.Value {
+				var event traceEvent
+				if json.Unmarshal(raw, &event) == nil {
+					r.events = append(r.events, event)
+				}
+			}
+			r.mu.Unlock()
+		case *tracing.EventTracingComplete:
+			r.mu.Lock()
+			if r.complete != nil {
+				r.complete <- ev.DataLossOccurred
+				r.complete = nil
+			}
+			r.mu.Unlock()
+		}
+	})
+	return r
+}
+
+func (r *traceRecorder) Start(ctx context.Context) error {
+	r.mu.Lock()
+	r.events = nil
+	r.complete = make(chan bool, 1)
+	r.mu.Unlock()
+
+	return tracing.Start().
+		WithTransferMode(tracing.TransferModeReportEvents).
+		WithTraceConfig(&tracing.TraceConfig{IncludedCategories: scrollTraceCategories}).
+		Do(ctx)
+}
+
+// Stop ends tracing and returns the recorded events, and whether the trace
+// is complete rather than missing events that didn't fit in the buffer.
+func (r *traceRecorder) Stop(ctx context.Context) ([]traceEvent, bool, error) {
+	r.mu.Lock()
+	complete := r.complete
+	r.mu.Unlock()
+
+	if err := tracing.End().Do(ctx); err != nil {
+		return nil, false, err
+	}
+
+	var dataLoss bool
+	select {
+	case dataLoss = <-complete:
+	case <-time.After(10 * time.Second):
+		return nil, false, fmt.Errorf("timed out waiting for trace data")
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events, !dataLoss, nil
+}
+
+// countTraceFrames counts the frames the page's renderer drew and dropped,
+// using the same events as the DevTools Performance panel.
+func countTraceFrames(events []traceEvent) (drawn, dropped int) {
+	// The browser's own compositor draws frames too, so only count the
+	// renderer hosting the main frame when the trace says which it is
+	rendererPid := 0
+	for _, event := range events {
+		if event.Name != "TracingStartedInBrowser" {
+			continue
+		}
+		var args struct {
+			Data struct {
+				Frames []struct {
+					ProcessID int    `json:"processId"`
+					Parent    string `json:"parent"`
+				} `json:"frames"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(event.Args, &args) != nil {
+			continue
+		}
+		for _, frame := range args.Data.Frames {
+			if frame.Parent == "" && frame.ProcessID != 0 {
+				rendererPid = frame.ProcessID
+			}
+		}
+	}
+
+	for _, event := range events {
+		if rendererPid != 0 && event.Pid != rendererPid {
+			continue
+		}
+		switch event.Name {
+		case "DrawFrame":
+			drawn++
+		case "DroppedFrame":
+			dropped++
+		}
+	}
+	return drawn, dropped
+}
+
+// scrollPage generates the page scrolled by the test: a sticky blurred
+// header over a long feed of cards with text, gradients, shadows and inline
+// SVG, which is roughly what makes real pages expensive to scroll.
+func scrollPage() string {
+	var cards strings.Builder
+	for i := 0; i < 300; i++ {
+		hue := i * 37 % 360
+		fmt.Fprintf(&cards, `
+			<article class="card" style="--hue: %d">
+				<svg viewBox="0 0 120 80" width="120" height="80">
+					<rect width="120" height="80" rx="8" fill="hsl(%d, 60%%, 85%%)"/>
+					<circle cx="%d" cy="40" r="%d" fill="hsl(%d, 70%%, 50%%)" opacity="0.8"/>
+					<path d="M0 70 Q 30 %d 60 60 T 120 %d V 80 H 0 Z" fill="hsl(%d, 50%%, 40%%)"/>
+				</svg>
+				<div>
+					<h2>Item %d</h2>
+					<p>%s</p>
+					<p>%s</p>
+				</div>
+			</article>`,
+			hue, hue, 20+i*13%80, 10+i%20, (hue+120)%360, 40+i%30, 50+i*7%30, (hue+240)%360,
+			i+1, scrollPageText[i%len(scrollPageText)], scrollPageText[(i+3)%len(scrollPageText)])
+	}
+
+	return `<!DOCTYPE html>
+<html>
+<head>
+	<title>Scroll Test</title>
+	<style>
+		body { margin: 0; font-family: sans-serif; background: linear-gradient(#f4f4f8, #dde3ee); }
+		header {
+			position: sticky; top: 0; z-index: 1; padding: 16px 24px;
+			background: rgba(255, 255, 255, 0.6); backdrop-filter: blur(12px);
+			box-shadow: 0 2px 8px rgba(0, 0, 0, 0.2);
+		}
+		main { max-width: 960px; margin: 0 auto; padding: 24px; }
+		.card {
+			display: flex; gap: 16px; margin-bottom: 24px; padding: 16px; border-radius: 12px;
+			background: linear-gradient(135deg, hsl(var(--hue), 80%, 97%), hsl(var(--hue), 60%, 88%));
+			box-shadow: 0 4px 16px rgba(0, 0, 0, 0.15), inset 0 1px 0 rgba(255, 255, 255, 0.8);
+		}
+		.card h2 { margin: 0 0 8px; color: hsl(var(--hue), 50%, 30%); text-shadow: 0 1px 1px rgba(0, 0, 0, 0.2); }
+		.card p { margin: 0 0 8px; line-height: 1.5; }
+	</style>
+</head>
+<body>
+	<header><h1>Scroll Test</h1></header>
+	<main>` + cards.String() + `
+	</main>
+	<script>
+		// Record requestAnimationFrame timestamps between startFrames and
+		// stopFrames
+		let frames = null;
+		window.startFrames = () => {
+			const recording = [];
+			frames = recording;
+			const tick = (t) => {
+				if (frames !== recording) return;
+				recording.push(t);
+				requestAnimationFrame(tick);
+			};
+			requestAnimationFrame(tick);
+		};
+		window.stopFrames = () => {
+			const recording = frames || [];
+			frames = null;
+			return recording;
+		};
+	</script>
+</body>
+</html>
+`
+}
+
+var scrollPageText = []string{
+	"Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.",
+	"Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat.",
+	"Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur.",
+	"Excepteur sint occaecat cupidatat non proident, sunt in culpa qui officia deserunt mollit anim id est laborum.",
+	"Sed ut perspiciatis unde omnis iste natus error sit voluptatem accusantium doloremque laudantium, totam rem aperiam.",
+	"Nemo enim ipsam voluptatem quia voluptas sit aspernatur aut odit aut fugit, sed quia consequuntur magni dolores.",
+	"Neque porro quisquam est, qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit, sed quia non numquam.",
+}
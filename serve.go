@@ -0,0 +1,715 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states reported by the serve API.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const (
+	// maxFinishedJobs is how many finished jobs the server remembers;
+	// older ones are forgotten. Their runs stay in the history.
+	maxFinishedJobs = 100
+	// maxJobEvents is how many events a job keeps for streaming. Once a
+	// job has more, the oldest are dropped.
+	maxJobEvents = 10000
+	// workerShutdownTimeout is how long shutdown waits for a running job
+	// to clean up and save its results.
+	workerShutdownTimeout = time.Minute
+)
+
+// serveAllowedFlags are the Chrome flags API clients may pass, with or
+// without a =value. Flags such as --renderer-cmd-prefix run arbitrary
+// commands, so anything else has to be allowed with -allow-flag.
+var serveAllowedFlags = []string{
+	"--enable-features",
+	"--disable-features",
+	"--enable-gpu-rasterization",
+	"--disable-gpu-rasterization",
+	"--enable-zero-copy",
+	"--disable-zero-copy",
+	"--disable-gpu",
+	"--disable-gpu-compositing",
+	"--disable-gpu-vsync",
+	"--disable-frame-rate-limit",
+	"--ignore-gpu-blocklist",
+	"--use-gl",
+	"--use-angle",
+	"--use-vulkan",
+	"--disable-accelerated-video-decode",
+	"--disable-accelerated-video-encode",
+	"--num-raster-threads",
+	"--force-device-scale-factor",
+	"--window-size",
+}
+
+// RunRequest is the body of POST /runs. Empty fields take the same defaults
+// as the command line flags. Chrome is the label of one of the server's
+// -chrome builds.
+type RunRequest struct {
+	Include      []string
+	Exclude      []string
+	Chrome       string
+	ChromeFlags  []string
+	Headless     bool
+	Collectors   []string
+	Isolation    string
+	RequireIdle  string
+	Timeout      string
+	TestTimeouts map[string]string
+	Thresholds   []string
+}
+
+// serveJob is a queued or finished run.
+type serveJob struct {
+	ID      string
+	Request RunRequest
+
+	mu        sync.Mutex
+	status    string
+	err       string
+	submitted time.Time
+	started   time.Time
+	finished  time.Time
+	record    *HistoryRecord
+	historyID string
+	events    []Event
+	// droppedEvents is how many of the oldest events were dropped to stay
+	// under maxJobEvents
+	droppedEvents int
+	changed       chan struct{}
+	cancel        context.CancelFunc
+	harness       *TestHarness
+	thresholds    []Threshold
+}
+
+// jobView is the JSON representation of a job.
+type jobView struct {
+	ID        string
+	Status    string
+	Error     string `json:",omitempty"`
+	Request   RunRequest
+	Submitted time.Time
+	Started   *time.Time     `json:",omitempty"`
+	Finished  *time.Time     `json:",omitempty"`
+	HistoryID string         `json:",omitempty"`
+	Result    *HistoryRecord `json:",omitempty"`
+}
+
+func (j *serveJob) view(withResult bool) jobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	v := jobView{
+		ID:        j.ID,
+		Status:    j.status,
+		Error:     j.err,
+		Request:   j.Request,
+		Submitted: j.submitted,
+		HistoryID: j.historyID,
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		v.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		v.Finished = &finished
+	}
+	if withResult {
+		v.Result = j.record
+	}
+	return v
+}
+
+// emit appends an event and wakes up anyone streaming the job's progress.
+func (j *serveJob) emit(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.emitLocked(event)
+}
+
+func (j *serveJob) emitLocked(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	j.events = append(j.events, event)
+	if len(j.events) > maxJobEvents {
+		// Drop the oldest half at once rather than one event at a time
+		drop := len(j.events) - maxJobEvents/2
+		j.events = append([]Event(nil), j.events[drop:]...)
+		j.droppedEvents += drop
+	}
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// eventsSince returns the events after the first n ever emitted, skipping any
+// that have been dropped, the number emitted so far, whether the job has
+// finished, and a channel that is closed when more events arrive.
+func (j *serveJob) eventsSince(n int) ([]Event, int, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	start := n - j.droppedEvents
+	if start < 0 {
+		start = 0
+	}
+	return j.events[start:], j.droppedEvents + len(j.events), j.isFinished(), j.changed
+}
+
+func (j *serveJob) hasFinished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.isFinished()
+}
+
+func (j *serveJob) isFinished() bool {
+	return j.status == JobDone || j.status == JobFailed || j.status == JobCancelled
+}
+
+// setStatus records a state change and emits it as an event in one step, so
+// a stream that sees the job finished has also seen its final event.
+func (j *serveJob) setStatus(status, errText string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.setStatusLocked(status, errText)
+}
+
+func (j *serveJob) setStatusLocked(status, errText string) {
+	j.status = status
+	j.err = errText
+	switch status {
+	case JobRunning:
+		j.started = time.Now()
+	case JobDone, JobFailed, JobCancelled:
+		j.finished = time.Now()
+	}
+	j.emitLocked(Event{Type: status, Message: errText})
+}
+
+// server runs submitted jobs one at a time so only one benchmark uses the
+// machine at once.
+type server struct {
+	assets       *AssetConfig
+	videoCache   *VideoCache
+	allTests     []Test
+	noHistory    bool
+	token        string
+	binaries     []ChromeBinary
+	allowedFlags []string
+
+	mu     sync.Mutex
+	jobs   map[string]*serveJob
+	order  []string
+	nextID int
+	queue  chan *serveJob
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var (
+		addr         = fs.String("addr", "127.0.0.1:8080", "Address to listen on")
+		token        = fs.String("token", "", "Bearer token clients must send; required unless -addr is a loopback address (default $CHROMEBENCH_TOKEN)")
+		offline      = fs.Bool("offline", false, "Refuse all network fetches and fail runs whose test assets are missing")
+		mirror       = fs.String("mirror", "", "Base URL of a mirror to fetch remote test assets from")
+		noHistory    = fs.Bool("no-history", false, "Don't record runs in ~/.chromebench/history")
+		binaries     chromeBinariesFlag
+		allowedFlags stringList
+	)
+	fs.Var(&binaries, "chrome", "Chrome build runs may select by label, as label=path (repeatable)")
+	fs.Var(&allowedFlags, "allow-flag", "Also allow runs to pass this Chrome flag (repeatable)")
+	fs.Parse(args)
+
+	// The environment is read here rather than used as the flag's default so
+	// the token never appears in usage output
+	if *token == "" {
+		*token = os.Getenv("CHROMEBENCH_TOKEN")
+	}
+	if *token == "" && !isLoopbackAddr(*addr) {
+		log.Fatalf("-addr %s is reachable from other machines; set -token or CHROMEBENCH_TOKEN", *addr)
+	}
+
+	assets := &AssetConfig{Offline: *offline, Mirror: *mirror}
+	videoCache, err := NewVideoCache(assets)
+	if err != nil {
+		log.Fatalf("Failed to initialize video cache: %v", err)
+	}
+
+	s := &server{
+		assets:       assets,
+		videoCache:   videoCache,
+		allTests:     registerTests(assets, videoCache),
+		noHistory:    *noHistory,
+		token:        *token,
+		binaries:     binaries,
+		allowedFlags: append(append([]string{}, serveAllowedFlags...), allowedFlags...),
+		jobs:         make(map[string]*serveJob),
+		queue:        make(chan *serveJob, 100),
+	}
+
+	ctx, cancel := interruptContext(os.Stdout)
+	defer cancel()
+
+	var worker sync.WaitGroup
+	worker.Add(1)
+	go func() {
+		defer worker.Done()
+		s.worker(ctx)
+	}()
+
+	httpServer := &http.Server{Addr: *addr, Handler: s.routes()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on http://%s\n", *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+
+	// Let a running job close its browser and save its results
+	done := make(chan struct{})
+	go func() {
+		worker.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(workerShutdownTimeout):
+		log.Printf("Gave up waiting for the running job to stop")
+	}
+}
+
+// stringList collects a repeated string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// isLoopbackAddr reports whether addr only accepts connections from this
+// machine.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tests", s.handleTests)
+	mux.HandleFunc("GET /runs", s.handleListRuns)
+	mux.HandleFunc("POST /runs", s.handleSubmit)
+	mux.HandleFunc("GET /runs/{id}", s.handleGetRun)
+	mux.HandleFunc("GET /runs/{id}/events", s.handleEvents)
+	mux.HandleFunc("POST /runs/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("DELETE /runs/{id}", s.handleCancel)
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the server's bearer token, if it has
+// one.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) handleTests(w http.ResponseWriter, r *http.Request) {
+	var tests []string
+	for _, test := range s.allTests {
+		tests = append(tests, test.Name())
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{
+		"Tests":      tests,
+		"Collectors": availableCollectors(),
+	})
+}
+
+func (s *server) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	views := make([]jobView, 0, len(s.order))
+	for _, id := range s.order {
+		views = append(views, s.jobs[id].view(false))
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %v", err))
+		return
+	}
+
+	harness, thresholds, err := s.newHarness(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	job := &serveJob{
+		ID:         fmt.Sprintf("%d", s.nextID),
+		Request:    req,
+		status:     JobQueued,
+		submitted:  time.Now(),
+		changed:    make(chan struct{}),
+		harness:    harness,
+		thresholds: thresholds,
+	}
+	job.emit(Event{Type: JobQueued})
+	select {
+	case s.queue <- job:
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("job queue is full"))
+		return
+	}
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	s.evictFinishedLocked()
+	s.mu.Unlock()
+
+	w.Header().Set("Location", "/runs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job.view(false))
+}
+
+// evictFinishedLocked forgets the oldest finished jobs beyond
+// maxFinishedJobs.
+func (s *server) evictFinishedLocked() {
+	finished := 0
+	for _, id := range s.order {
+		if s.jobs[id].hasFinished() {
+			finished++
+		}
+	}
+
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if finished > maxFinishedJobs && s.jobs[id].hasFinished() {
+			delete(s.jobs, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+func (s *server) handleGetRun(w http.ResponseWriter, r *http.Request) {
+	job := s.job(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such run"))
+		return
+	}
+	writeJSON(w, http.StatusOK, job.view(true))
+}
+
+// handleEvents streams a job's progress as newline-delimited JSON until the
+// job finishes or the client goes away.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	job := s.job(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such run"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	sent := 0
+	for {
+		events, next, finished, changed := job.eventsSince(sent)
+		for _, event := range events {
+			if err := enc.Encode(event); err != nil {
+				return
+			}
+		}
+		sent = next
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if finished {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	job := s.job(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such run"))
+		return
+	}
+
+	// Decide under the lock so the worker can't start the job in between
+	job.mu.Lock()
+	status := job.status
+	switch status {
+	case JobQueued:
+		// The worker skips cancelled jobs when it reaches them
+		job.setStatusLocked(JobCancelled, "")
+	case JobRunning:
+		job.cancel()
+	}
+	job.mu.Unlock()
+
+	if status != JobQueued && status != JobRunning {
+		writeError(w, http.StatusConflict, fmt.Errorf("run already %s", status))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job.view(false))
+}
+
+func (s *server) job(id string) *serveJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+// worker runs queued jobs one after another until ctx is cancelled.
+func (s *server) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.runJob(ctx, job)
+		}
+	}
+}
+
+func (s *server) runJob(ctx context.Context, job *serveJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Check and leave the queued state in one step so a cancel can't slip
+	// in between
+	job.mu.Lock()
+	if job.status != JobQueued {
+		job.mu.Unlock()
+		return
+	}
+	job.cancel = cancel
+	job.setStatusLocked(JobRunning, "")
+	job.mu.Unlock()
+
+	h := job.harness
+	h.onEvent = job.emit
+
+	if err := prepareAssets(h.tests, s.assets, s.videoCache); err != nil {
+		job.setStatus(JobFailed, err.Error())
+		return
+	}
+
+	run, err := h.RunTests(jobCtx)
+	if err != nil {
+		if jobCtx.Err() != nil {
+			job.setStatus(JobCancelled, err.Error())
+		} else {
+			job.setStatus(JobFailed, err.Error())
+		}
+		return
+	}
+	run.Violations = evaluateThresholds(os.Stdout, run, job.thresholds, nil)
+
+	record := newHistoryRecord(run, h)
+	historyID := ""
+	if !s.noHistory {
+		if historyID, err = saveHistory(run, h); err != nil {
+			log.Printf("Failed to save run to history: %v", err)
+		}
+	}
+	record.ID = historyID
+
+	job.mu.Lock()
+	job.record = record
+	job.historyID = historyID
+	job.mu.Unlock()
+
+	if run.Interrupted {
+		job.setStatus(JobCancelled, "")
+	} else {
+		job.setStatus(JobDone, "")
+	}
+}
+
+// newHarness validates req and builds the harness for it.
+func (s *server) newHarness(req RunRequest) (*TestHarness, []Threshold, error) {
+	h := &TestHarness{
+		chromeFlags: req.ChromeFlags,
+		headless:    req.Headless,
+		preflight: PreflightConfig{
+			Policy:        IdlePolicyWarn,
+			MaxCPUPercent: 10,
+			Timeout:       2 * time.Minute,
+		},
+		collectors: defaultCollectors,
+		isolation:  IsolateNone,
+		timeout:    defaultTestTimeout,
+		out:        os.Stdout,
+	}
+
+	if req.Chrome != "" {
+		found := false
+		for _, b := range s.binaries {
+			if b.Label == req.Chrome {
+				h.label, h.execPath = b.Label, b.Path
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("unknown Chrome %q; the server has %s", req.Chrome, s.chromeLabels())
+		}
+	}
+
+	for _, f := range req.ChromeFlags {
+		if !s.flagAllowed(f) {
+			return nil, nil, fmt.Errorf("Chrome flag %s is not allowed; the server operator can allow it with -allow-flag", f)
+		}
+	}
+
+	h.tests = filterTests(s.allTests, strings.Join(req.Include, ","), strings.Join(req.Exclude, ","))
+	if len(h.tests) == 0 {
+		return nil, nil, fmt.Errorf("no tests to run")
+	}
+
+	if req.RequireIdle != "" {
+		if !validIdlePolicy(req.RequireIdle) {
+			return nil, nil, fmt.Errorf("invalid RequireIdle policy %q", req.RequireIdle)
+		}
+		h.preflight.Policy = req.RequireIdle
+	}
+
+	if len(req.Collectors) > 0 {
+		collectors, err := parseCollectors(strings.Join(req.Collectors, ","))
+		if err != nil {
+			return nil, nil, err
+		}
+		h.collectors = collectors
+	}
+
+	if req.Isolation != "" {
+		if !validIsolation(req.Isolation) {
+			return nil, nil, fmt.Errorf("invalid Isolation %q", req.Isolation)
+		}
+		h.isolation = req.Isolation
+	}
+
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid Timeout: %v", err)
+		}
+		h.timeout = d
+	}
+
+	h.testTimeouts = make(map[string]time.Duration)
+	for name, value := range req.TestTimeouts {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid timeout for %s: %v", name, err)
+		}
+		h.testTimeouts[name] = d
+	}
+
+	var thresholds []Threshold
+	for _, value := range req.Thresholds {
+		t, err := parseThreshold(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if t.Relative {
+			return nil, nil, fmt.Errorf("baseline thresholds aren't supported by the API: %s", t)
+		}
+		thresholds = append(thresholds, t)
+	}
+
+	return h, thresholds, nil
+}
+
+func (s *server) chromeLabels() string {
+	if len(s.binaries) == 0 {
+		return "no -chrome builds"
+	}
+	var labels []string
+	for _, b := range s.binaries {
+		labels = append(labels, b.Label)
+	}
+	return strings.Join(labels, ", ")
+}
+
+// flagAllowed reports whether flag, ignoring any =value, is allowed.
+func (s *server) flagAllowed(flag string) bool {
+	name, _, _ := strings.Cut(flag, "=")
+	for _, allowed := range s.allowedFlags {
+		if name == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"Error": err.Error()})
+}
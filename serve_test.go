@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer returns a server with two fake tests and one -chrome build,
+// without a worker, so submitted jobs stay queued.
+func newTestServer(t *testing.T, token string) (*server, *httptest.Server) {
+	t.Helper()
+	s := &server{
+		allTests:     []Test{namedTest("basic"), namedTest("other")},
+		token:        token,
+		binaries:     []ChromeBinary{{Label: "beta", Path: "/opt/chrome-beta/chrome"}},
+		allowedFlags: append(append([]string{}, serveAllowedFlags...), "--custom-flag"),
+		jobs:         make(map[string]*serveJob),
+		queue:        make(chan *serveJob, 100),
+	}
+	ts := httptest.NewServer(s.routes())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func doRequest(t *testing.T, method, url, auth string, body interface{}) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestServeAuthentication(t *testing.T) {
+	tests := []struct {
+		token string
+		auth  string
+		want  int
+	}{
+		{token: "secret", auth: "", want: http.StatusUnauthorized},
+		{token: "secret", auth: "Bearer wrong", want: http.StatusUnauthorized},
+		{token: "secret", auth: "Bearer secretx", want: http.StatusUnauthorized},
+		{token: "secret", auth: "secret", want: http.StatusUnauthorized},
+		{token: "secret", auth: "Basic c2VjcmV0", want: http.StatusUnauthorized},
+		{token: "secret", auth: "Bearer secret", want: http.StatusOK},
+		{token: "", auth: "", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		_, ts := newTestServer(t, tt.token)
+		for _, path := range []string{"/tests", "/runs"} {
+			resp := doRequest(t, http.MethodGet, ts.URL+path, tt.auth, nil)
+			if resp.StatusCode != tt.want {
+				t.Errorf("token %q, Authorization %q: GET %s = %d, want %d", tt.token, tt.auth, path, resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("token %q, Authorization %q: no WWW-Authenticate challenge", tt.token, tt.auth)
+			}
+		}
+	}
+}
+
+func TestServeSubmitValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      RunRequest
+		want     int
+		wantPath string
+	}{
+		{name: "defaults", req: RunRequest{}, want: http.StatusAccepted},
+		{name: "chrome label", req: RunRequest{Chrome: "beta"}, want: http.StatusAccepted, wantPath: "/opt/chrome-beta/chrome"},
+		{name: "chrome path", req: RunRequest{Chrome: "/usr/bin/google-chrome"}, want: http.StatusBadRequest},
+		{name: "unknown chrome label", req: RunRequest{Chrome: "canary"}, want: http.StatusBadRequest},
+		{name: "allowed flag", req: RunRequest{ChromeFlags: []string{"--enable-features=Vulkan", "--disable-gpu"}}, want: http.StatusAccepted},
+		{name: "operator allowed flag", req: RunRequest{ChromeFlags: []string{"--custom-flag=1"}}, want: http.StatusAccepted},
+		{name: "command prefix flag", req: RunRequest{ChromeFlags: []string{"--renderer-cmd-prefix=sh -c id"}}, want: http.StatusBadRequest},
+		{name: "flag sharing a prefix", req: RunRequest{ChromeFlags: []string{"--disable-gpu-sandbox"}}, want: http.StatusBadRequest},
+		{name: "one bad flag among good", req: RunRequest{ChromeFlags: []string{"--disable-gpu", "--utility-cmd-prefix=x"}}, want: http.StatusBadRequest},
+		{name: "unknown test", req: RunRequest{Include: []string{"nope"}}, want: http.StatusBadRequest},
+		{name: "bad timeout", req: RunRequest{Timeout: "soon"}, want: http.StatusBadRequest},
+		{name: "baseline threshold", req: RunRequest{Thresholds: []string{"score >= baseline"}}, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		s, ts := newTestServer(t, "")
+		resp := doRequest(t, http.MethodPost, ts.URL+"/runs", "", tt.req)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: POST /runs = %d, want %d", tt.name, resp.StatusCode, tt.want)
+			continue
+		}
+		if tt.want != http.StatusAccepted {
+			if len(s.jobs) != 0 {
+				t.Errorf("%s: rejected request was queued", tt.name)
+			}
+			continue
+		}
+		job := s.job("1")
+		if job == nil {
+			t.Errorf("%s: job not recorded", tt.name)
+			continue
+		}
+		if job.harness.execPath != tt.wantPath {
+			t.Errorf("%s: Chrome path = %q, want %q", tt.name, job.harness.execPath, tt.wantPath)
+		}
+	}
+}
+
+func TestServeCancel(t *testing.T) {
+	s, ts := newTestServer(t, "")
+	doRequest(t, http.MethodPost, ts.URL+"/runs", "", RunRequest{})
+	doRequest(t, http.MethodPost, ts.URL+"/runs", "", RunRequest{})
+
+	// A queued job is cancelled on the spot
+	resp := doRequest(t, http.MethodPost, ts.URL+"/runs/1/cancel", "", nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("cancel queued job = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	var view jobView
+	if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	if view.Status != JobCancelled {
+		t.Errorf("cancelled job status = %s, want %s", view.Status, JobCancelled)
+	}
+
+	// and the worker doesn't start it later
+	s.runJob(context.Background(), s.job("1"))
+	if status := s.job("1").view(false).Status; status != JobCancelled {
+		t.Errorf("cancelled job status after worker = %s, want %s", status, JobCancelled)
+	}
+
+	// A running job has its context cancelled
+	job := s.job("2")
+	called := false
+	job.mu.Lock()
+	job.cancel = func() { called = true }
+	job.setStatusLocked(JobRunning, "")
+	job.mu.Unlock()
+	if resp := doRequest(t, http.MethodDelete, ts.URL+"/runs/2", "", nil); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("cancel running job = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if !called {
+		t.Error("running job's context wasn't cancelled")
+	}
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/runs/1/cancel", http.StatusConflict},
+		{http.MethodDelete, "/runs/1", http.StatusConflict},
+		{http.MethodPost, "/runs/99/cancel", http.StatusNotFound},
+		{http.MethodDelete, "/runs/99", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if resp := doRequest(t, tt.method, ts.URL+tt.path, "", nil); resp.StatusCode != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestServeEventsOfFinishedJob(t *testing.T) {
+	_, ts := newTestServer(t, "")
+	doRequest(t, http.MethodPost, ts.URL+"/runs", "", RunRequest{})
+	doRequest(t, http.MethodPost, ts.URL+"/runs/1/cancel", "", nil)
+
+	// The stream ends once it has sent the job's final event
+	resp := doRequest(t, http.MethodGet, ts.URL+"/runs/1/events", "", nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var types []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("bad event %q: %v", scanner.Text(), err)
+		}
+		types = append(types, event.Type)
+	}
+	if got := strings.Join(types, ","); got != JobQueued+","+JobCancelled {
+		t.Errorf("events = %s, want %s,%s", got, JobQueued, JobCancelled)
+	}
+}
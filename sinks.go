@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resultSink posts each finished test to an HTTP endpoint.
+type resultSink struct {
+	name        string
+	url         string
+	contentType string
+	header      http.Header
+	encode      func(run *RunResult, result *TestResult) ([]byte, error)
+}
+
+// newInfluxSink writes results in InfluxDB line protocol to url, e.g.
+// http://influx:8086/api/v2/write?org=lab&bucket=chromebench. token, if set,
+// is sent as an InfluxDB API token.
+func newInfluxSink(url, token string) *resultSink {
+	s := &resultSink{
+		name:        "influx",
+		url:         url,
+		contentType: "text/plain; charset=utf-8",
+		header:      make(http.Header),
+		encode:      encodeInfluxLine,
+	}
+	if token != "" {
+		s.header.Set("Authorization", "Token "+token)
+	}
+	return s
+}
+
+// newWebhookSink posts results as JSON to url.
+func newWebhookSink(url string) *resultSink {
+	return &resultSink{
+		name:        "webhook",
+		url:         url,
+		contentType: "application/json",
+		header:      make(http.Header),
+		encode:      encodeWebhookJSON,
+	}
+}
+
+// webhookPayload is the JSON body posted to -webhook for each test.
+type webhookPayload struct {
+	Host        string
+	Label       string
+	Browser     string
+	ChromeFlags []string
+	RunStart    time.Time
+	Test        HistoryTest
+}
+
+func encodeWebhookJSON(run *RunResult, result *TestResult) ([]byte, error) {
+	host, _ := os.Hostname()
+	payload := webhookPayload{
+		Host:        host,
+		Label:       run.Label,
+		ChromeFlags: run.ChromeFlags,
+		RunStart:    run.StartTime,
+		Test:        newHistoryTest(*result, nil),
+	}
+	if run.Environment != nil {
+		payload.Browser = run.Environment.Browser
+	}
+	return json.Marshal(payload)
+}
+
+// encodeInfluxLine encodes result as a single "chromebench" point tagged with
+// the test, browser and host, with every metric as a field.
+func encodeInfluxLine(run *RunResult, result *TestResult) ([]byte, error) {
+	host, _ := os.Hostname()
+	browser := ""
+	if run.Environment != nil {
+		browser = run.Environment.Browser
+	}
+
+	var b strings.Builder
+	b.WriteString("chromebench")
+	tags := [][2]string{
+		{"browser", browser},
+		{"configuration", run.Label},
+		{"flags", strings.Join(run.ChromeFlags, " ")},
+		{"host", host},
+		{"test", result.TestName},
+	}
+	for _, tag := range tags {
+		// Empty tag values aren't allowed
+		if tag[1] != "" {
+			fmt.Fprintf(&b, ",%s=%s", tag[0], escapeInfluxTag(tag[1]))
+		}
+	}
+
+	fields := []string{
+		"success=" + strconv.FormatBool(result.Success),
+		"duration_seconds=" + strconv.FormatFloat(result.EndTime.Sub(result.StartTime).Seconds(), 'f', -1, 64),
+	}
+	if result.Error != nil {
+		fields = append(fields, "error="+quoteInfluxString(result.Error.Error()))
+	}
+
+	// Sort keys alphabetically
+	keys := make([]string, 0, len(result.Metrics))
+	for key := range result.Metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var value string
+		switch v := result.Metrics[key].(type) {
+		case bool:
+			value = strconv.FormatBool(v)
+		case string:
+			value = quoteInfluxString(v)
+		default:
+			f, ok := numericValue(v)
+			if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+				continue
+			}
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		fields = append(fields, escapeInfluxTag(key)+"="+value)
+	}
+
+	end := result.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	fmt.Fprintf(&b, " %s %d\n", strings.Join(fields, ","), end.UnixNano())
+	return []byte(b.String()), nil
+}
+
+func escapeInfluxTag(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`).Replace(s)
+}
+
+func quoteInfluxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// spooledDelivery is a delivery saved to ~/.chromebench/spool because its
+// sink was unreachable. Headers aren't saved so tokens stay off disk.
+type spooledDelivery struct {
+	Sink    string
+	URL     string
+	Body    string
+	Created time.Time
+}
+
+type sinkDelivery struct {
+	sink      *resultSink
+	body      []byte
+	spoolFile string
+}
+
+// sinkDispatcher delivers results to the sinks in the background so a slow
+// endpoint doesn't hold up the next test. Deliveries are retried; ones that
+// still fail are spooled to disk and resent the next time chromebench runs
+// with the same sink.
+type sinkDispatcher struct {
+	sinks    []*resultSink
+	client   *http.Client
+	spoolDir string
+	queue    chan sinkDelivery
+	done     chan struct{}
+
+	mu   sync.Mutex
+	down map[*resultSink]bool
+	seq  int
+}
+
+const sinkAttempts = 3
+
+// sinkRetryDelay is the wait before the second attempt, growing linearly
+// after that.
+var sinkRetryDelay = 2 * time.Second
+
+// sinkRejectedError is a response that resending can't fix, such as a body
+// the endpoint can't parse or a bad token.
+type sinkRejectedError struct {
+	url     string
+	status  string
+	message string
+}
+
+func (e *sinkRejectedError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("%s returned %s", e.url, e.status)
+	}
+	return fmt.Sprintf("%s returned %s: %s", e.url, e.status, e.message)
+}
+
+func newSinkDispatcher(sinks []*resultSink) (*sinkDispatcher, error) {
+	spoolDir, err := chromebenchDir("spool")
+	if err != nil {
+		return nil, err
+	}
+
+	d := &sinkDispatcher{
+		sinks:    sinks,
+		client:   &http.Client{Timeout: 15 * time.Second},
+		spoolDir: spoolDir,
+		queue:    make(chan sinkDelivery, 256),
+		done:     make(chan struct{}),
+		down:     make(map[*resultSink]bool),
+	}
+	go d.loop()
+	d.resendSpool()
+	return d, nil
+}
+
+// Send queues result for delivery to every sink. It is safe to call on a nil
+// dispatcher.
+func (d *sinkDispatcher) Send(run *RunResult, result *TestResult) {
+	if d == nil {
+		return
+	}
+	for _, sink := range d.sinks {
+		body, err := sink.encode(run, result)
+		if err != nil {
+			log.Printf("Failed to encode result for %s sink: %v", sink.name, err)
+			continue
+		}
+		select {
+		case d.queue <- sinkDelivery{sink: sink, body: body}:
+		default:
+			d.spool(sinkDelivery{sink: sink, body: body})
+		}
+	}
+}
+
+// Close waits for queued deliveries to finish.
+func (d *sinkDispatcher) Close() {
+	if d == nil {
+		return
+	}
+	close(d.queue)
+	<-d.done
+}
+
+func (d *sinkDispatcher) loop() {
+	defer close(d.done)
+	for delivery := range d.queue {
+		d.mu.Lock()
+		down := d.down[delivery.sink]
+		d.mu.Unlock()
+
+		err := fmt.Errorf("endpoint unreachable earlier in this run")
+		if !down {
+			err = d.deliver(delivery)
+		}
+		var rejected *sinkRejectedError
+		if errors.As(err, &rejected) {
+			// The endpoint is up, it just won't take this result
+			log.Printf("Dropping result for %s sink: %v", delivery.sink.name, err)
+		}
+		if err == nil || rejected != nil {
+			if delivery.spoolFile != "" {
+				os.Remove(delivery.spoolFile)
+			}
+			continue
+		}
+
+		if !down {
+			log.Printf("Failed to send result to %s sink, spooling: %v", delivery.sink.name, err)
+			// Don't wait on every later result for an endpoint that's down
+			d.mu.Lock()
+			d.down[delivery.sink] = true
+			d.mu.Unlock()
+		}
+		if delivery.spoolFile == "" {
+			d.spool(delivery)
+		}
+	}
+}
+
+func (d *sinkDispatcher) deliver(delivery sinkDelivery) error {
+	var err error
+	for attempt := 1; attempt <= sinkAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(sinkRetryDelay * time.Duration(attempt-1))
+		}
+		err = d.post(delivery)
+		var rejected *sinkRejectedError
+		if err == nil || errors.As(err, &rejected) {
+			return err
+		}
+	}
+	return err
+}
+
+func (d *sinkDispatcher) post(delivery sinkDelivery) error {
+	req, err := http.NewRequest(http.MethodPost, delivery.sink.url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return err
+	}
+	for key, values := range delivery.sink.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", delivery.sink.contentType)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	case resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &sinkRejectedError{url: delivery.sink.url, status: resp.Status, message: strings.TrimSpace(string(msg))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return fmt.Errorf("%s returned %s", delivery.sink.url, resp.Status)
+}
+
+func (d *sinkDispatcher) spool(delivery sinkDelivery) {
+	d.mu.Lock()
+	d.seq++
+	name := fmt.Sprintf("%s-%s-%d.json", time.Now().Format("20060102-150405.000"), delivery.sink.name, d.seq)
+	d.mu.Unlock()
+
+	record := spooledDelivery{
+		Sink:    delivery.sink.name,
+		URL:     delivery.sink.url,
+		Body:    string(delivery.body),
+		Created: time.Now(),
+	}
+	if err := writeJSONFile(filepath.Join(d.spoolDir, name), record); err != nil {
+		log.Printf("Failed to spool result for %s sink: %v", delivery.sink.name, err)
+	}
+}
+
+// resendSpool queues spooled deliveries for the configured sinks, oldest
+// first, ahead of this run's results.
+func (d *sinkDispatcher) resendSpool() {
+	files, err := filepath.Glob(filepath.Join(d.spoolDir, "*.json"))
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var record spooledDelivery
+		if err := json.Unmarshal(data, &record); err != nil {
+			continue
+		}
+		for _, sink := range d.sinks {
+			if sink.name == record.Sink && sink.url == record.URL {
+				select {
+				case d.queue <- sinkDelivery{sink: sink, body: []byte(record.Body), spoolFile: file}:
+				default:
+					// Leave the rest for next time
+					return
+				}
+				break
+			}
+		}
+	}
+}
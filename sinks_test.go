@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEscapeInfluxTag(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"stable", "stable"},
+		{"my build", `my\ build`},
+		{"a=b", `a\=b`},
+		{"a,b", `a\,b`},
+		{"--flag=1 --other,x", `--flag\=1\ --other\,x`},
+		{"two\nlines", `two\nlines`},
+		{`say "hi"`, `say\ "hi"`},
+	}
+	for _, tt := range tests {
+		if got := escapeInfluxTag(tt.in); got != tt.want {
+			t.Errorf("escapeInfluxTag(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteInfluxString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", `""`},
+		{"page failed to load", `"page failed to load"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\chrome`, `"C:\\chrome"`},
+		{"two\nlines", `"two\nlines"`},
+		{"a=b, c", `"a=b, c"`},
+	}
+	for _, tt := range tests {
+		if got := quoteInfluxString(tt.in); got != tt.want {
+			t.Errorf("quoteInfluxString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// testSinkDispatcher returns a dispatcher for a webhook at url whose spool is
+// in a temporary home directory.
+func testSinkDispatcher(t *testing.T, url string) (*sinkDispatcher, *resultSink) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	delay := sinkRetryDelay
+	sinkRetryDelay = time.Millisecond
+	t.Cleanup(func() { sinkRetryDelay = delay })
+
+	sink := newWebhookSink(url)
+	d, err := newSinkDispatcher([]*resultSink{sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, sink
+}
+
+func spooledFiles(t *testing.T, d *sinkDispatcher) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(d.spoolDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestSinkDispatcherRejected(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantCalls int32
+		wantSpool int
+	}{
+		{http.StatusBadRequest, 2, 0},
+		{http.StatusUnauthorized, 2, 0},
+		{http.StatusRequestTimeout, sinkAttempts, 2},
+		{http.StatusTooManyRequests, sinkAttempts, 2},
+	}
+	for _, tt := range tests {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			http.Error(w, "nope", tt.status)
+		}))
+
+		d, _ := testSinkDispatcher(t, server.URL)
+		run := &RunResult{Label: "stable"}
+		d.Send(run, &TestResult{TestName: "a"})
+		d.Send(run, &TestResult{TestName: "b"})
+		d.Close()
+		server.Close()
+
+		// A rejected result is dropped without marking the sink down, so the
+		// next result is still sent; an unreachable sink is tried once
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Errorf("%d: endpoint called %d times, want %d", tt.status, got, tt.wantCalls)
+		}
+		if got := len(spooledFiles(t, d)); got != tt.wantSpool {
+			t.Errorf("%d: %d results spooled, want %d", tt.status, got, tt.wantSpool)
+		}
+	}
+}
+
+func TestSinkDispatcherRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "busy", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	d, _ := testSinkDispatcher(t, server.URL)
+	d.Send(&RunResult{Label: "stable"}, &TestResult{TestName: "a"})
+	d.Close()
+
+	if got := calls.Load(); got != sinkAttempts {
+		t.Errorf("endpoint called %d times, want %d", got, sinkAttempts)
+	}
+	if files := spooledFiles(t, d); len(files) != 0 {
+		t.Errorf("delivered result was spooled: %v", files)
+	}
+}
+
+func TestSinkDispatcherSpools(t *testing.T) {
+	// Nothing listens on a closed server's address
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	d, _ := testSinkDispatcher(t, url)
+	sink := newInfluxSink(url, "secret-token")
+	d.sinks = []*resultSink{sink}
+	run := &RunResult{Label: "stable"}
+	d.Send(run, &TestResult{TestName: "a"})
+	d.Send(run, &TestResult{TestName: "b"})
+	d.Close()
+
+	// The sink is marked down after the first result, and both are spooled
+	if !d.down[sink] {
+		t.Error("unreachable sink not marked down")
+	}
+	files := spooledFiles(t, d)
+	if len(files) != 2 {
+		t.Fatalf("%d results spooled, want 2", len(files))
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret-token") {
+			t.Errorf("%s contains the sink's token", filepath.Base(file))
+		}
+		var record spooledDelivery
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Sink != "influx" || record.URL != url {
+			t.Errorf("spooled for %s %s, want influx %s", record.Sink, record.URL, url)
+		}
+		if !strings.HasPrefix(record.Body, "chromebench") {
+			t.Errorf("spooled body = %q", record.Body)
+		}
+	}
+}
+
+func TestSinkDispatcherResendsSpool(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Token secret-token" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// Spool two results as a run that couldn't reach the endpoint would
+	d, _ := testSinkDispatcher(t, server.URL)
+	sink := newInfluxSink(server.URL, "secret-token")
+	d.spool(sinkDelivery{sink: sink, body: []byte("first")})
+	d.spool(sinkDelivery{sink: sink, body: []byte("second")})
+	d.Close()
+
+	// A run with another sink leaves the spool alone
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("spooled result sent to another sink")
+	}))
+	defer other.Close()
+	d2, err := newSinkDispatcher([]*resultSink{newInfluxSink(other.URL, "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2.Close()
+	if got := len(spooledFiles(t, d2)); got != 2 {
+		t.Fatalf("%d results spooled after another sink ran, want 2", got)
+	}
+
+	// The next run with the same sink resends them oldest first, with the
+	// sink's headers, and removes them
+	d3, err := newSinkDispatcher([]*resultSink{newInfluxSink(server.URL, "secret-token")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d3.Close()
+	mu.Lock()
+	got := strings.Join(received, ",")
+	mu.Unlock()
+	if got != "first,second" {
+		t.Errorf("resent %s, want first,second", got)
+	}
+	if files := spooledFiles(t, d3); len(files) != 0 {
+		t.Errorf("resent results still spooled: %v", files)
+	}
+}
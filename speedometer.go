@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// speedometerBundleURL is the pinned Speedometer 3 release.
+const speedometerBundleURL = "https://github.com/WebKit/Speedometer/archive/refs/tags/v3.0.zip"
+
+type SpeedometerTest struct {
+	bundle *BenchmarkBundle
+}
+
+func (t *SpeedometerTest) Name() string {
+	return "speedometer"
+}
+
+// Timeout allows for the default 10 iterations on a slow machine.
+func (t *SpeedometerTest) Timeout() time.Duration {
+	return 15 * time.Minute
+}
+
+func (t *SpeedometerTest) Bundle() *BenchmarkBundle {
+	return t.bundle
+}
+
+// speedometerMetric is the part of a Speedometer Metric the test reads.
+type speedometerMetric struct {
+	Name  string
+	Mean  float64
+	Delta float64
+}
+
+// speedometerMetricsJS returns benchmarkClient's metrics once the run has
+// finished, or null while it is still running.
+const speedometerMetricsJS = `(() => {
+	const client = window.benchmarkClient;
+	const metrics = client && (client.metrics || client._metrics);
+	const result = document.getElementById("result-number");
+	if (!metrics || !result || result.textContent.trim() === "") return null;
+	return Object.values(metrics).map(m => ({
+		Name: m.name,
+		Mean: m.mean || 0,
+		Delta: m.delta || 0
+	}));
+})()`
+
+func (t *SpeedometerTest) Run(ctx context.Context) (*TestResult, error) {
+	result := &TestResult{
+		TestName:  t.Name(),
+		StartTime: time.Now(),
+		Metrics:   make(map[string]interface{}),
+	}
+
+	baseURL, stop, err := t.bundle.Serve()
+	if err != nil {
+		result.EndTime = time.Now()
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+	defer stop()
+
+	var metrics []speedometerMetric
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(baseURL+"/index.html?startAutomatically=true"),
+		chromedp.WaitReady("body"),
+
+		// Wait for all iterations to finish
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			ticker := time.NewTicker(2 * time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-ticker.C:
+					if err := chromedp.Evaluate(speedometerMetricsJS, &metrics).Do(ctx); err != nil {
+						return err
+					}
+					if metrics != nil {
+						return nil
+					}
+				}
+			}
+		}),
+	)
+
+	result.EndTime = time.Now()
+
+	if err != nil {
+		result.Success = false
+		result.Error = err
+		return result, err
+	}
+
+	for _, m := range metrics {
+		switch {
+		case m.Name == "Score":
+			result.Metrics["overall_score"] = m.Mean
+			result.Metrics["overall_score_confidence"] = m.Delta
+		case m.Name == "Total":
+			result.Metrics["total_ms"] = m.Mean
+		case !strings.Contains(m.Name, "/") && m.Name != "Geomean" && !strings.HasPrefix(m.Name, "Iteration-"):
+			// Suites are the workloads, their steps are named suite/step
+			result.Metrics[fmt.Sprintf("workload_%s_ms", m.Name)] = m.Mean
+		}
+	}
+
+	if _, ok := result.Metrics["overall_score"]; !ok {
+		result.Success = false
+		result.Error = fmt.Errorf("speedometer reported no score")
+		return result, result.Error
+	}
+
+	result.Success = true
+	return result, nil
+}
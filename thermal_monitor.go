@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThermalMonitor samples thermal zones and CPU frequencies on Linux while a
+// test is running. On other platforms it records nothing.
+type ThermalMonitor struct {
+	*poller
+	throttleBefore uint64
+	throttleAfter  uint64
+}
+
+func NewThermalMonitor() *ThermalMonitor {
+	return &ThermalMonitor{poller: newPoller(1*time.Second, sampleThermal)}
+}
+
+func (m *ThermalMonitor) Name() string {
+	return "thermal"
+}
+
+func (m *ThermalMonitor) Start(ctx context.Context) error {
+	m.throttleBefore = readThrottleCount()
+	m.start()
+	return nil
+}
+
+func (m *ThermalMonitor) Stop() {
+	m.poller.Stop()
+	m.throttleAfter = readThrottleCount()
+}
+
+// Throttled reports whether the CPU was thermally throttled at any point
+// between Start and Stop, either because the kernel's throttle counters
+// increased or because a CPU cooling device was engaged.
+func (m *ThermalMonitor) Throttled() bool {
+	if m.throttleAfter > m.throttleBefore {
+		return true
+	}
+	for _, s := range m.getSamples() {
+		if s.Values["cooling"] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ThermalMonitor) Results() ([]Sample, map[string]interface{}) {
+	samples := m.getSamples()
+	return samples, m.summarize(samples)
+}
+
+func (m *ThermalMonitor) summarize(samples []Sample) map[string]interface{} {
+	metrics := make(map[string]interface{})
+	metrics["thermal_throttled"] = m.Throttled()
+	if len(samples) == 0 {
+		return metrics
+	}
+
+	var maxTemp, freqTotal float64
+	minFreq := samples[0].Values["min_freq_mhz"]
+	for _, s := range samples {
+		if s.Values["max_temp_c"] > maxTemp {
+			maxTemp = s.Values["max_temp_c"]
+		}
+		freqTotal += s.Values["avg_freq_mhz"]
+		if s.Values["min_freq_mhz"] < minFreq {
+			minFreq = s.Values["min_freq_mhz"]
+		}
+	}
+
+	if maxTemp > 0 {
+		metrics["thermal_max_temp_c"] = maxTemp
+	}
+	if freqTotal > 0 {
+		metrics["cpu_freq_avg_mhz"] = freqTotal / float64(len(samples))
+		metrics["cpu_freq_min_mhz"] = minFreq
+	}
+	return metrics
+}
+
+// sampleThermal reads the hottest thermal zone in °C, the average and
+// minimum scaling_cur_freq across all CPUs, and whether any CPU cooling
+// device is active (1) or not (0).
+func sampleThermal() (map[string]float64, error) {
+	maxTemp := maxTemperature()
+	freqs := readCPUFrequencies()
+	if maxTemp == 0 && len(freqs) == 0 {
+		return nil, fmt.Errorf("no thermal zones or CPU frequencies")
+	}
+
+	values := map[string]float64{
+		"max_temp_c":   maxTemp,
+		"avg_freq_mhz": 0,
+		"min_freq_mhz": 0,
+		"cooling":      0,
+	}
+	if cpuCoolingActive() {
+		values["cooling"] = 1
+	}
+	if len(freqs) > 0 {
+		var total float64
+		minFreq := freqs[0]
+		for _, f := range freqs {
+			total += f
+			if f < minFreq {
+				minFreq = f
+			}
+		}
+		values["avg_freq_mhz"] = total / float64(len(freqs))
+		values["min_freq_mhz"] = minFreq
+	}
+	return values, nil
+}
+
+func maxTemperature() float64 {
+	var max float64
+	for _, temp := range readThermalZones() {
+		if temp > max {
+			max = temp
+		}
+	}
+	return max
+}
+
+// readCPUFrequencies returns the current frequency of every CPU in MHz.
+func readCPUFrequencies() []float64 {
+	var freqs []float64
+	files, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
+	for _, file := range files {
+		khz, err := readSysfsFloat(file)
+		if err == nil {
+			freqs = append(freqs, khz/1000)
+		}
+	}
+	return freqs
+}
+
+// cpuCoolingActive reports whether any CPU cooling device, such as the
+// cpufreq cooling used by ARM thermal drivers or the ACPI processor cooling
+// device, is currently limiting performance.
+func cpuCoolingActive() bool {
+	devices, _ := filepath.Glob("/sys/class/thermal/cooling_device*")
+	for _, device := range devices {
+		deviceType, err := os.ReadFile(filepath.Join(device, "type"))
+		if err != nil {
+			continue
+		}
+		t := strings.ToLower(strings.TrimSpace(string(deviceType)))
+		if !strings.Contains(t, "cpu") && !strings.Contains(t, "processor") {
+			continue
+		}
+		state, err := readSysfsFloat(filepath.Join(device, "cur_state"))
+		if err == nil && state > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// readThrottleCount sums the x86 core and package thermal throttle counters.
+func readThrottleCount() uint64 {
+	var total uint64
+	files, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/thermal_throttle/*_throttle_count")
+	for _, file := range files {
+		count, err := readSysfsFloat(file)
+		if err == nil {
+			total += uint64(count)
+		}
+	}
+	return total
+}
+
+func readSysfsFloat(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
+
+// CoolDown waits until the hottest thermal zone drops below threshold °C or
+// timeout elapses or ctx is cancelled, returning how long it waited.
+func CoolDown(ctx context.Context, out io.Writer, threshold float64, timeout time.Duration) time.Duration {
+	start := time.Now()
+	temp := maxTemperature()
+	if temp == 0 || temp < threshold {
+		return 0
+	}
+
+	fmt.Fprintf(out, "Cooling down from %.1f°C to below %.1f°C...\n", temp, threshold)
+	deadline := start.Add(timeout)
+	for temp >= threshold && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return time.Since(start)
+		case <-time.After(2 * time.Second):
+		}
+		temp = maxTemperature()
+	}
+
+	if temp >= threshold {
+		fmt.Fprintf(out, "Cooldown timed out at %.1f°C\n", temp)
+	}
+	return time.Since(start)
+}
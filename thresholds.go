@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Exit codes used when a run completes but doesn't pass.
+const (
+	exitTestFailure        = 3
+	exitThresholdViolation = 4
+	exitInterrupted        = 130
+)
+
+// Threshold is a performance budget for a metric, e.g.
+// "motionmark overall_score >= 900" or "drop_rate_percent <= 1". Without a
+// test name it applies to every test that reports the metric.
+type Threshold struct {
+	Test   string
+	Metric string
+	Op     string
+	Value  float64
+
+	// Relative thresholds compare against the metric's value in the
+	// baseline, adjusted by Value percent when Percent is set or by Value
+	// otherwise, e.g. "overall_score >= baseline-5%".
+	Relative bool
+	Percent  bool
+}
+
+func (t Threshold) String() string {
+	s := t.Metric
+	if t.Test != "" {
+		s = t.Test + " " + s
+	}
+	s += " " + t.Op + " "
+	if !t.Relative {
+		return s + strconv.FormatFloat(t.Value, 'g', -1, 64)
+	}
+	s += "baseline"
+	if t.Value != 0 {
+		s += fmt.Sprintf("%+g", t.Value)
+		if t.Percent {
+			s += "%"
+		}
+	}
+	return s
+}
+
+// limit returns the value the metric is compared with, or false if the
+// threshold is relative and the baseline has no value for it.
+func (t Threshold) limit(test string, baseline Baseline) (float64, bool) {
+	if !t.Relative {
+		return t.Value, true
+	}
+	base, ok := baseline[test][t.Metric]
+	if !ok {
+		return 0, false
+	}
+	if t.Percent {
+		return base * (1 + t.Value/100), true
+	}
+	return base + t.Value, true
+}
+
+func (t Threshold) passes(value, limit float64) bool {
+	switch t.Op {
+	case ">=":
+		return value >= limit
+	case "<=":
+		return value <= limit
+	case ">":
+		return value > limit
+	case "<":
+		return value < limit
+	}
+	return false
+}
+
+// parseThreshold parses "[test] metric op value", where value is a number or
+// baseline, optionally followed by +N, -N, +N% or -N%.
+func parseThreshold(s string) (Threshold, error) {
+	fields := strings.Fields(s)
+	var t Threshold
+	switch len(fields) {
+	case 3:
+		t.Metric, t.Op = fields[0], fields[1]
+	case 4:
+		t.Test, t.Metric, t.Op = fields[0], fields[1], fields[2]
+	default:
+		return t, fmt.Errorf("invalid threshold %q, expected \"[test] metric op value\"", s)
+	}
+
+	switch t.Op {
+	case ">=", "<=", ">", "<":
+	default:
+		return t, fmt.Errorf("invalid operator %q in threshold %q", t.Op, s)
+	}
+
+	value := fields[len(fields)-1]
+	if rest, ok := strings.CutPrefix(value, "baseline"); ok {
+		t.Relative = true
+		if rest == "" {
+			return t, nil
+		}
+		rest, t.Percent = strings.CutSuffix(rest, "%")
+		if !strings.HasPrefix(rest, "+") && !strings.HasPrefix(rest, "-") {
+			return t, fmt.Errorf("invalid baseline offset in threshold %q", s)
+		}
+		value = rest
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return t, fmt.Errorf("invalid value in threshold %q", s)
+	}
+	t.Value = v
+	return t, nil
+}
+
+// thresholdsFlag collects repeated -threshold flags.
+type thresholdsFlag []Threshold
+
+func (f *thresholdsFlag) String() string {
+	var parts []string
+	for _, t := range *f {
+		parts = append(parts, t.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f *thresholdsFlag) Set(value string) error {
+	t, err := parseThreshold(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, t)
+	return nil
+}
+
+// readThresholds reads one threshold per line from path. Blank lines and
+// lines starting with # are ignored.
+func readThresholds(path string) ([]Threshold, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var thresholds []Threshold
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := parseThreshold(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, scanner.Err()
+}
+
+// Baseline holds the numeric metrics of a previous run, keyed by test name
+// and metric. It is written with -save-baseline and read with -baseline.
+type Baseline map[string]map[string]float64
+
+func newBaseline(run *RunResult) Baseline {
+	baseline := make(Baseline)
+	for _, result := range run.Results {
+		if !result.Success {
+			continue
+		}
+		metrics := make(map[string]float64)
+		for key, value := range result.Metrics {
+			if v, ok := numericValue(value); ok {
+				metrics[key] = v
+			}
+		}
+		baseline[result.TestName] = metrics
+	}
+	return baseline
+}
+
+func loadBaseline(path string) (Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("parsing baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+func (b Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ThresholdViolation is a threshold a test's metric didn't meet.
+type ThresholdViolation struct {
+	TestName  string
+	Threshold Threshold
+	Value     float64
+	Limit     float64
+	Missing   bool
+}
+
+func (v ThresholdViolation) String() string {
+	if v.Missing {
+		return fmt.Sprintf("%s: %s not reported (%s)", v.TestName, v.Threshold.Metric, v.Threshold)
+	}
+	s := fmt.Sprintf("%s: %s = %.4g, want %s %.4g", v.TestName, v.Threshold.Metric, v.Value, v.Threshold.Op, v.Limit)
+	if v.Threshold.Relative {
+		s += fmt.Sprintf(" (%s)", v.Threshold)
+	}
+	return s
+}
+
+// evaluateThresholds checks every successful test in run against thresholds.
+// Failed tests are already reported as errors and aren't checked.
+func evaluateThresholds(out io.Writer, run *RunResult, thresholds []Threshold, baseline Baseline) []ThresholdViolation {
+	var violations []ThresholdViolation
+	for _, result := range run.Results {
+		if !result.Success {
+			continue
+		}
+		for _, t := range thresholds {
+			if t.Test != "" && t.Test != result.TestName {
+				continue
+			}
+
+			value, ok := numericValue(result.Metrics[t.Metric])
+			if !ok {
+				// A threshold for any test only applies where the metric
+				// is reported
+				if t.Test != "" {
+					violations = append(violations, ThresholdViolation{TestName: result.TestName, Threshold: t, Missing: true})
+				}
+				continue
+			}
+
+			limit, ok := t.limit(result.TestName, baseline)
+			if !ok {
+				fmt.Fprintf(out, "Note: no baseline for %s %s, skipping threshold %s\n", result.TestName, t.Metric, t)
+				continue
+			}
+			if !t.passes(value, limit) {
+				violations = append(violations, ThresholdViolation{
+					TestName:  result.TestName,
+					Threshold: t,
+					Value:     value,
+					Limit:     limit,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+func printViolations(w io.Writer, run *RunResult) {
+	if len(run.Violations) == 0 {
+		return
+	}
+	if run.Label != "" {
+		fmt.Fprintf(w, "=== Threshold Violations: %s ===\n", run.Label)
+	} else {
+		fmt.Fprintln(w, "=== Threshold Violations ===")
+	}
+	fmt.Fprintln(w)
+	for _, v := range run.Violations {
+		fmt.Fprintf(w, "  %s\n", v)
+	}
+	fmt.Fprintln(w)
+}
+
+// failedTests counts the tests in run that didn't succeed.
+func failedTests(run *RunResult) int {
+	failed := 0
+	for _, result := range run.Results {
+		if !result.Success {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Threshold
+		wantErr bool
+	}{
+		{in: "load_ms <= 500", want: Threshold{Metric: "load_ms", Op: "<=", Value: 500}},
+		{in: "basic load_ms < 1.5", want: Threshold{Test: "basic", Metric: "load_ms", Op: "<", Value: 1.5}},
+		{in: "  fps   >=  55 ", want: Threshold{Metric: "fps", Op: ">=", Value: 55}},
+		{in: "overall_score > -1", want: Threshold{Metric: "overall_score", Op: ">", Value: -1}},
+		{in: "overall_score >= baseline", want: Threshold{Metric: "overall_score", Op: ">=", Relative: true}},
+		{in: "overall_score >= baseline-5%", want: Threshold{Metric: "overall_score", Op: ">=", Value: -5, Relative: true, Percent: true}},
+		{in: "speedometer total_ms <= baseline+20", want: Threshold{Test: "speedometer", Metric: "total_ms", Op: "<=", Value: 20, Relative: true}},
+		{in: "", wantErr: true},
+		{in: "load_ms <=", wantErr: true},
+		{in: "a b c d e", wantErr: true},
+		{in: "load_ms == 500", wantErr: true},
+		{in: "load_ms <= fast", wantErr: true},
+		{in: "overall_score >= baseline5", wantErr: true},
+		{in: "overall_score >= baseline-x%", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseThreshold(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseThreshold(%q) = %+v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseThreshold(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseThreshold(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -19,6 +19,11 @@ func (t *VideoTest) Name() string {
 	return t.name
 }
 
+// Timeout allows for the 30 second playback window plus page load.
+func (t *VideoTest) Timeout() time.Duration {
+	return 2 * time.Minute
+}
+
 func (t *VideoTest) Run(ctx context.Context) (*TestResult, error) {
 	result := &TestResult{
 		TestName:  t.Name(),
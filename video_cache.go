@@ -11,6 +11,7 @@ import (
 
 type VideoCache struct {
 	cacheDir string
+	assets   *AssetConfig
 }
 
 type VideoInfo struct {
@@ -84,18 +85,27 @@ var testVideos = []VideoInfo{
 	},
 }
 
-func NewVideoCache() (*VideoCache, error) {
+// chromebenchDir returns ~/.chromebench/<sub>, creating it if needed.
+func chromebenchDir(sub string) (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	dir := filepath.Join(homeDir, ".chromebench", sub)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
 	}
+	return dir, nil
+}
 
-	cacheDir := filepath.Join(homeDir, ".chromebench", "videos")
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+func NewVideoCache(assets *AssetConfig) (*VideoCache, error) {
+	cacheDir, err := chromebenchDir("videos")
+	if err != nil {
 		return nil, err
 	}
 
-	return &VideoCache{cacheDir: cacheDir}, nil
+	return &VideoCache{cacheDir: cacheDir, assets: assets}, nil
 }
 
 func (vc *VideoCache) GetVideoPath(videoInfo VideoInfo) string {
@@ -119,7 +129,12 @@ func (vc *VideoCache) DownloadVideo(videoInfo VideoInfo) error {
 		return nil
 	}
 
-	fmt.Printf("Downloading %s from %s...\n", videoInfo.Name, videoInfo.URL)
+	if vc.assets.Offline {
+		return fmt.Errorf("%s: %w", videoInfo.Name, errOffline)
+	}
+
+	downloadURL := vc.assets.ResolveURL(videoInfo.URL)
+	fmt.Fprintf(vc.assets.output(), "Downloading %s from %s...\n", videoInfo.Name, downloadURL)
 
 	// Create temporary file
 	tmpPath := localPath + ".tmp"
@@ -130,7 +145,7 @@ func (vc *VideoCache) DownloadVideo(videoInfo VideoInfo) error {
 	defer out.Close()
 
 	// Download the file
-	resp, err := http.Get(videoInfo.URL)
+	resp, err := vc.assets.Get(videoInfo.URL)
 	if err != nil {
 		os.Remove(tmpPath)
 		return err
@@ -150,6 +165,7 @@ func (vc *VideoCache) DownloadVideo(videoInfo VideoInfo) error {
 		Reader: resp.Body,
 		Total:  size,
 		Name:   videoInfo.Name,
+		Out:    vc.assets.output(),
 	}
 
 	// Copy the file
@@ -168,11 +184,24 @@ func (vc *VideoCache) DownloadVideo(videoInfo VideoInfo) error {
 		return err
 	}
 
-	fmt.Printf("\nDownloaded %s successfully\n", videoInfo.Name)
+	fmt.Fprintf(vc.assets.output(), "\nDownloaded %s successfully\n", videoInfo.Name)
 	return nil
 }
 
 func (vc *VideoCache) EnsureAllVideos() error {
+	if vc.assets.Offline {
+		var missing []string
+		for _, video := range testVideos {
+			if !vc.IsVideoCached(video) {
+				missing = append(missing, fmt.Sprintf("%s: %s (not in cache)", video.Name, vc.GetVideoPath(video)))
+			}
+		}
+		if len(missing) > 0 {
+			return &MissingAssetsError{Assets: missing}
+		}
+		return nil
+	}
+
 	downloadedAny := false
 
 	for _, video := range testVideos {
@@ -185,7 +214,7 @@ func (vc *VideoCache) EnsureAllVideos() error {
 	}
 
 	if downloadedAny {
-		fmt.Println("All videos cached successfully")
+		fmt.Fprintln(vc.assets.output(), "All videos cached successfully")
 	}
 	return nil
 }
@@ -195,6 +224,7 @@ type progressReader struct {
 	Total      int64
 	Downloaded int64
 	Name       string
+	Out        io.Writer
 	lastPrint  int64
 }
 
@@ -207,7 +237,7 @@ func (pr *progressReader) Read(p []byte) (int, error) {
 		pr.lastPrint = pr.Downloaded
 		if pr.Total > 0 {
 			percent := float64(pr.Downloaded) / float64(pr.Total) * 100
-			fmt.Printf("\r%s: %.1f%% (%.1f MB / %.1f MB)",
+			fmt.Fprintf(pr.Out, "\r%s: %.1f%% (%.1f MB / %.1f MB)",
 				pr.Name,
 				percent,
 				float64(pr.Downloaded)/(1024*1024),